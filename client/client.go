@@ -4,20 +4,33 @@
 //
 // It consumes the `core` package to communicate
 // with the server.
+//
+// Besides the interactive menu (`Start`), `Client`
+// exposes the typed V1 API as plain Go methods so other
+// programs can drive the vegitable service. Each Client
+// owns its connection, so any number of them can be used
+// side by side.
 package client
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
-	"fmt"
-	"github.com/olekukonko/tablewriter"
-	"log"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
-	"os"
+	"reflect"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/dimalkavindu/go-rpc/core"
 	"github.com/dimalkavindu/go-rpc/menu"
+	"github.com/dimalkavindu/go-rpc/render"
 )
 
 // Client contains the configuration options for
@@ -28,175 +41,564 @@ import (
 // if the server is offered via HTTP, it should have
 // the property UseHttp set to true.
 type Client struct {
+	Host    string
 	Port    uint
 	UseHttp bool
 	UseJson bool
+	TLS     *tls.Config
 	client  *rpc.Client
+
+	// Token is the API token used to login. It is
+	// exchanged for a session on Init and again
+	// whenever the server forgets the session.
+	Token string
+
+	// Timeout bounds every command of the interactive
+	// menu. Zero means no timeout. API callers control
+	// deadlines through the context they pass in.
+	Timeout time.Duration
+
+	// Retries is the number of times a broken
+	// connection is redialed before a call gives up.
+	// Zero uses DefaultRetries, a negative value
+	// disables reconnecting.
+	Retries int
+
+	// BackoffBase and BackoffMax bound the jittered
+	// exponential delay between redials. Zero uses
+	// DefaultBackoffBase and DefaultBackoffMax.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+
+	// HistoryFile keeps the lines entered in the
+	// interactive menu across runs. Empty keeps them
+	// for the run only.
+	HistoryFile string
+
+	// Output is the format the menu writes results in,
+	// tables when empty.
+	Output render.Format
+
+	// mutex guards client, closed, session,
+	// interrupt and random.
+	mutex   sync.Mutex
+	closed  bool
+	session string
+	random  *rand.Rand
+
+	// dialMutex makes sure a broken connection is
+	// redialed only once.
+	dialMutex sync.Mutex
+
+	// interrupt cancels the menu command in flight,
+	// it is nil when there is none.
+	interrupt context.CancelFunc
+
+	// cart holds the lines of the order being built
+	// with the 'cart' menu command until it is
+	// checked out.
+	cart []core.OrderLine
+
+	// role is the role of the session as last seen by
+	// the menu, commands it may not use are hidden
+	// from menu.
+	role     core.Role
+	commands []menu.CommandOption
+	menu     *menu.Menu
+
+	// out writes the results of the menu commands.
+	out *render.Renderer
+}
+
+// Error is returned by the API methods when the server
+// processed the call but the operation failed.
+type Error struct {
+	Code    core.ErrorCode
+	Message string
+
+	// LineErrors explains every rejected line when
+	// an order fails.
+	LineErrors []core.OrderLineError
+}
+
+func (e *Error) Error() string {
+	return e.Code.String() + ": " + e.Message
+}
+
+// CodeOf returns the code of an *Error, or CodeOK for
+// nil and errors that did not come from the server.
+func CodeOf(err error) core.ErrorCode {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return core.CodeOK
 }
 
 // Init initializes the underlying RPC client that is
 // responsible for taking a codec and writing the RPC
 // details down to it.
 //
-// Here we're using basic `(json)rpc.Dial*` but we could
-// instead make use of raw TCP connections and tune them
-// accordingly if we'd take this to production as we could
-// then specify various timeouts and options for the transport
-// layer.
+// Here we're dialing raw TCP (or TLS) connections and
+// wrapping them with the codec of the chosen transport, which
+// leaves room to tune timeouts and options of the transport
+// layer if we'd take this to production.
 //
 // Note.: the HTTP thing is just a very thin layer of HTTP
-// that is sent via the TCP connection. We could ditch that
-// of and replace by a `CONNECT` call followed by checking
-// the HTTP response that we got back.
+// that is sent via the TCP connection: a `CONNECT` call
+// followed by checking the HTTP response that we got back.
+// Doing it by hand lets it run over TLS too.
 //
-// Note.: we're not setting TLS here either but it's a very
-// simple thing given that we can have total control over
-// the underlying connection.
-var client *Client
-
+// Note.: when TLS is set every transport runs on top of a
+// TLS connection, the configuration decides whether the
+// client presents a certificate (mutual TLS).
 func (c *Client) Init() (err error) {
-	client = c
-	if client.Port == 0 {
+	if c.Port == 0 {
 		err = errors.New("client: port must be specified")
 		return
 	}
 
-	addr := "127.0.0.1:" + strconv.Itoa(int(client.Port))
+	conn, err := c.dial()
+	if err != nil {
+		return
+	}
+
+	c.mutex.Lock()
+	c.client = conn
+	c.closed = false
+	c.mutex.Unlock()
+
+	if c.Token != "" {
+		_, err = c.Login(context.Background(), c.Token)
+	}
+
+	return
+}
+
+// dial connects to the server using the transport chosen
+// by UseHttp and UseJson, over TLS when it is configured.
+func (c *Client) dial() (conn *rpc.Client, err error) {
+	host := c.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(c.Port)))
+
+	var raw net.Conn
+	if c.TLS != nil {
+		raw, err = tls.Dial("tcp", addr, c.TLS)
+	} else {
+		raw, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return
+	}
 
-	if client.UseHttp {
-		client.client, err = rpc.DialHTTP("tcp", addr)
-	} else if client.UseJson {
-		client.client, err = jsonrpc.Dial("tcp", addr)
+	if c.UseHttp {
+		conn, err = connectHTTP(raw)
+	} else if c.UseJson {
+		conn = jsonrpc.NewClient(raw)
 	} else {
-		client.client, err = rpc.Dial("tcp", addr)
+		conn = rpc.NewClient(raw)
+	}
+
+	return
+}
+
+// connectHTTP performs the `CONNECT` handshake of the
+// HTTP transport (what rpc.DialHTTP does) on an already
+// established connection.
+func connectHTTP(raw net.Conn) (conn *rpc.Client, err error) {
+	_, err = io.WriteString(raw, "CONNECT "+rpc.DefaultRPCPath+" HTTP/1.0\n\n")
+	if err != nil {
+		raw.Close()
+		return
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(raw), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status != "200 Connected to Go RPC" {
+		err = errors.New("client: unexpected HTTP response: " + resp.Status)
 	}
 	if err != nil {
+		raw.Close()
 		return
 	}
 
+	conn = rpc.NewClient(raw)
 	return
 }
 
 // Close gracefully terminates the underlying client.
 func (c *Client) Close() (err error) {
-	if client.client != nil {
-		err = client.client.Close()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	if c.client != nil {
+		err = c.client.Close()
+		c.client = nil
 		return
 	}
 
 	return
 }
 
-func showVegitable(args ...string) error {
-	var (
-		request  = &core.Request{Command: args}
-		response = new(core.Response)
-	)
+// conn returns the current connection, nil once the
+// client is closed.
+func (c *Client) conn() *rpc.Client {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	err := client.client.Call("Handler.CshowVegitable", request, response)
-	if err != nil {
-		log.Fatal(err)
-		return nil
-	}
-
-	if !response.Ok {
-		fmt.Println(response.Message)
-		return nil
-	}
-
-	if args[0] == "vegitable" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Unit Price", "Stocks(KG)"})
-
-		if args[1] == "all" {
-			for _, v := range response.Vegitables.Vegitables {
-				table.Append([]string{v.Name, v.PricePerKg, v.RemainingKgs})
-			}
-			table.Render()
-			return nil
-		} else {
-			var v = response.Vegitables.Vegitables[0]
-			table.Append([]string{v.Name, v.PricePerKg, v.RemainingKgs})
-			table.Render()
-			return nil
+	return c.client
+}
+
+// call invokes a V1 method. A failed Status is turned
+// into an *Error. The request must be a pointer so that
+// the session can be attached to it.
+//
+// The call is issued asynchronously so that it can be
+// abandoned as soon as ctx is done, in which case
+// ctx.Err() is returned. The server still completes the
+// abandoned call; its reply is decoded into a value of
+// its own that is dropped, response is left alone.
+//
+// When the connection turns out to be broken it is
+// redialed (see reconnect) and the call is retried if it
+// never reached the server or if the method only reads.
+func (c *Client) call(ctx context.Context, method string, request interface{}, response interface{}, status *core.Status) (err error) {
+	err = c.send(ctx, method, request, response, status)
+
+	// the server forgets sessions when it restarts or
+	// once they expire, login again and retry once.
+	if CodeOf(err) == core.CodeUnauthorized && c.Token != "" && method != "Login" {
+		_, err = c.Login(ctx, c.Token)
+		if err == nil {
+			// nothing of the rejected reply may be
+			// taken for the answer of the retry
+			reset(response)
+			err = c.send(ctx, method, request, response, status)
 		}
-	} else if args[0] == "price" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Unit Price"})
-
-		for _, v := range response.Vegitables.Vegitables {
-			if v.Name == args[1] {
-				table.Append([]string{v.Name, v.PricePerKg})
-			}
+	}
+
+	return
+}
+
+// reset sets the value response points to back to zero.
+func reset(response interface{}) {
+	value := reflect.ValueOf(response).Elem()
+	value.Set(reflect.Zero(value.Type()))
+}
+
+// send performs a single call of a V1 method on behalf of
+// call, with the current session attached to the request.
+func (c *Client) send(ctx context.Context, method string, request interface{}, response interface{}, status *core.Status) (err error) {
+	if r, ok := request.(interface{ SetSession(string) }); ok {
+		c.mutex.Lock()
+		r.SetSession(c.session)
+		c.mutex.Unlock()
+	}
+
+	for attempt := 0; ; attempt++ {
+		conn := c.conn()
+		if conn == nil {
+			return errors.New("client: not initialized")
 		}
-		table.Render()
-		return nil
-	} else if args[0] == "stocks" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Stocks(KG)"})
-
-		for _, v := range response.Vegitables.Vegitables {
-			if v.Name == args[1] {
-				table.Append([]string{v.Name, v.RemainingKgs})
-			}
+
+		err = ctx.Err()
+		if err != nil {
+			return
 		}
-		table.Render()
-		return nil
+
+		// every attempt gets a reply of its own, an
+		// abandoned one may still be written to
+		reply := reflect.New(reflect.TypeOf(response).Elem())
+		call := conn.Go("V1."+method, request, reply.Interface(), make(chan *rpc.Call, 1))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.Done:
+		}
+
+		reflect.ValueOf(response).Elem().Set(reply.Elem())
+
+		err = call.Error
+		if !isBroken(err) || attempt >= c.retries() {
+			break
+		}
+
+		rerr := c.reconnect(ctx, conn)
+		if rerr != nil {
+			return rerr
+		}
+
+		// rpc.ErrShutdown means the request was never
+		// written, anything else may have reached the
+		// server already.
+		if err != rpc.ErrShutdown && !idempotent[method] {
+			return
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	if !status.Ok {
+		err = &Error{Code: status.Code, Message: status.Message}
 	}
 
-	return nil
+	return
 }
 
-func addVegitable(args ...string) error {
-	var (
-		request  = &core.Request{Command: args}
-		response = new(core.Response)
-	)
+// Interrupt cancels the menu command in flight. It
+// reports whether there was one to cancel.
+func (c *Client) Interrupt() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	err := client.client.Call("Handler.CaddVegitable", request, response)
-	if err != nil {
-		log.Fatal(err)
-		return nil
+	if c.interrupt == nil {
+		return false
 	}
 
-	fmt.Println(response.Message)
-	return nil
+	c.interrupt()
+	c.interrupt = nil
+	return true
 }
 
-func updateVegitable(args ...string) error {
-	var (
-		request  = &core.Request{Command: args}
-		response = new(core.Response)
-	)
+// commandContext returns the context of a menu command:
+// it expires after Timeout and is cancelled by Interrupt.
+// The returned function must be called once the command
+// is done.
+func (c *Client) commandContext() (context.Context, context.CancelFunc) {
+	return c.interruptible(c.Timeout)
+}
 
-	err := client.client.Call("Handler.CupdateVegitable", request, response)
-	if err != nil {
-		log.Fatal(err)
-		return nil
+// interruptible returns a context cancelled by Interrupt
+// which, unless timeout is zero, also expires after
+// timeout.
+func (c *Client) interruptible(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancel = chain(cancelTimeout, cancel)
 	}
 
-	fmt.Println(response.Message)
-	return nil
+	c.mutex.Lock()
+	c.interrupt = cancel
+	c.mutex.Unlock()
+
+	return ctx, func() {
+		c.mutex.Lock()
+		c.interrupt = nil
+		c.mutex.Unlock()
+
+		cancel()
+	}
 }
 
-func (c *Client) Start() (err error) {
-	commandOptions := []menu.CommandOption{
-		menu.CommandOption{"show", "\n" +
-			"\tshow vegitable all\t: Shows all the vegitables\n" +
-			"\tshow vegitable <vegitable name>\t: Shows unit price and stocks of a given vegitable\n" +
-			"\tshow price <vegitable name>\t: Shows the unit price of a given vegitable\n" +
-			"\tshow stocks <vegitable name>\t: Shows the stocks of a given vegitable", showVegitable},
-		menu.CommandOption{"add", "\n" +
-			"\tadd vegitable <vegitable name> <unit price> <stocks(KG)>\t: Adds a new vegitable with a given unit price & a stock value in KG", addVegitable},
-		menu.CommandOption{"update", "\n" +
-			"\tupdate price <vegitable name> <unit price>\t: Updates the unit price of a given vegitable\n" +
-			"\tupdate stocks <vegitable name> <stocks(KG)>\t: Updates the stocks of a given vegitable", updateVegitable},
+// chain returns a CancelFunc calling every given one.
+func chain(cancels ...context.CancelFunc) context.CancelFunc {
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
 	}
+}
 
-	menuOptions := menu.NewMenuOptions("'menu' for help > ", 500)
+// Login exchanges an API token for a session which is
+// then sent along with every call of this client.
+func (c *Client) Login(ctx context.Context, token string) (core.SessionResponse, error) {
+	var response core.SessionResponse
 
-	menu := menu.NewMenu(commandOptions, menuOptions)
-	menu.Start()
+	err := c.send(ctx, "Login", &core.LoginRequest{Token: token}, &response, &response.Status)
+	if err != nil {
+		return response, err
+	}
 
-	return
+	c.mutex.Lock()
+	c.session = response.Session
+	c.mutex.Unlock()
+
+	return response, nil
+}
+
+// WhoAmI describes the session of this client.
+func (c *Client) WhoAmI(ctx context.Context) (core.SessionResponse, error) {
+	var response core.SessionResponse
+
+	err := c.call(ctx, "WhoAmI", &core.WhoAmIRequest{}, &response, &response.Status)
+	return response, err
+}
+
+// History returns who changed a vegitable, when and
+// how, oldest first.
+func (c *Client) History(ctx context.Context, name string) ([]core.AuditEntry, error) {
+	var response core.HistoryResponse
+
+	err := c.call(ctx, "History", &core.HistoryRequest{Name: name}, &response, &response.Status)
+	return response.Entries, err
+}
+
+// Watch waits up to wait for the changes of the inventory
+// from a sequence number of an epoch on. Start with a zero
+// epoch and from, then pass the Epoch and Next of the
+// previous response. A response with Reset carries the
+// whole inventory instead of the changes.
+func (c *Client) Watch(ctx context.Context, epoch int64, from uint64, wait time.Duration) (core.WatchResponse, error) {
+	var response core.WatchResponse
+
+	err := c.call(ctx, "Watch", &core.WatchRequest{Epoch: epoch, From: from, Wait: wait}, &response, &response.Status)
+	return response, err
+}
+
+// ListVegitables returns every vegitable in the inventory.
+func (c *Client) ListVegitables(ctx context.Context) ([]core.Vegitable, error) {
+	var response core.VegitablesResponse
+
+	err := c.call(ctx, "ListVegitables", &core.ListVegitablesRequest{}, &response, &response.Status)
+	return response.Vegitables, err
+}
+
+// GetVegitable returns the vegitable with the given name.
+func (c *Client) GetVegitable(ctx context.Context, name string) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "GetVegitable", &core.GetVegitableRequest{Name: name}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// GetPrice returns the unit price of a vegitable.
+func (c *Client) GetPrice(ctx context.Context, name string) (core.Money, error) {
+	var response core.PriceResponse
+
+	err := c.call(ctx, "GetPrice", &core.GetPriceRequest{Name: name}, &response, &response.Status)
+	return response.PricePerKg, err
+}
+
+// PriceAt returns the unit price and stocks a vegitable
+// had at the given time.
+func (c *Client) PriceAt(ctx context.Context, name string, at time.Time) (core.Revision, error) {
+	var response core.RevisionResponse
+
+	err := c.call(ctx, "PriceAt", &core.PriceAtRequest{Name: name, At: at}, &response, &response.Status)
+	return response.Revision, err
+}
+
+// PriceHistory returns every change of the unit price and
+// stocks of a vegitable, oldest first.
+func (c *Client) PriceHistory(ctx context.Context, name string) ([]core.Revision, error) {
+	var response core.RevisionsResponse
+
+	err := c.call(ctx, "PriceHistory", &core.PriceHistoryRequest{Name: name}, &response, &response.Status)
+	return response.Revisions, err
+}
+
+// GetStocks returns the stocks of a vegitable in KG.
+func (c *Client) GetStocks(ctx context.Context, name string) (core.Weight, error) {
+	var response core.StocksResponse
+
+	err := c.call(ctx, "GetStocks", &core.GetStocksRequest{Name: name}, &response, &response.Status)
+	return response.RemainingKgs, err
+}
+
+// AddVegitable adds a new vegitable to the inventory.
+func (c *Client) AddVegitable(ctx context.Context, v core.Vegitable) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "AddVegitable", &core.AddVegitableRequest{Vegitable: v}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// UpdatePrice sets the unit price of a vegitable and
+// returns the updated vegitable.
+func (c *Client) UpdatePrice(ctx context.Context, name string, price core.Money) (core.Vegitable, error) {
+	return c.UpdatePriceIfVersion(ctx, name, price, 0)
+}
+
+// UpdatePriceIfVersion is UpdatePrice failing with
+// core.CodeConflict when the vegitable is no longer at
+// the given version (e.g. as read with GetVegitable). The
+// vegitable returned with the conflict is the current one.
+func (c *Client) UpdatePriceIfVersion(ctx context.Context, name string, price core.Money, version uint64) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "UpdatePrice", &core.UpdatePriceRequest{Name: name, PricePerKg: price, Version: version}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// UpdateStocks sets the stocks of a vegitable and returns
+// the updated vegitable.
+func (c *Client) UpdateStocks(ctx context.Context, name string, kgs core.Weight) (core.Vegitable, error) {
+	return c.UpdateStocksIfVersion(ctx, name, kgs, 0)
+}
+
+// UpdateStocksIfVersion is UpdateStocks failing with
+// core.CodeConflict when the vegitable is no longer at
+// the given version.
+func (c *Client) UpdateStocksIfVersion(ctx context.Context, name string, kgs core.Weight, version uint64) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "UpdateStocks", &core.UpdateStocksRequest{Name: name, RemainingKgs: kgs, Version: version}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// DeleteVegitable removes a vegitable and returns it as it
+// was before the removal.
+func (c *Client) DeleteVegitable(ctx context.Context, name string) (core.Vegitable, error) {
+	return c.DeleteVegitableIfVersion(ctx, name, 0)
+}
+
+// DeleteVegitableIfVersion is DeleteVegitable failing
+// with core.CodeConflict when the vegitable is no longer
+// at the given version.
+func (c *Client) DeleteVegitableIfVersion(ctx context.Context, name string, version uint64) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "DeleteVegitable", &core.DeleteVegitableRequest{Name: name, Version: version}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// RenameVegitable gives a vegitable a new name and returns
+// the renamed vegitable.
+func (c *Client) RenameVegitable(ctx context.Context, name, newName string) (core.Vegitable, error) {
+	return c.RenameVegitableIfVersion(ctx, name, newName, 0)
+}
+
+// RenameVegitableIfVersion is RenameVegitable failing
+// with core.CodeConflict when the vegitable is no longer
+// at the given version.
+func (c *Client) RenameVegitableIfVersion(ctx context.Context, name, newName string, version uint64) (core.Vegitable, error) {
+	var response core.VegitableResponse
+
+	err := c.call(ctx, "RenameVegitable", &core.RenameVegitableRequest{Name: name, NewName: newName, Version: version}, &response, &response.Status)
+	return response.Vegitable, err
+}
+
+// Purchase buys a quantity of a vegitable.
+func (c *Client) Purchase(ctx context.Context, name string, kgs core.Weight) (core.Receipt, error) {
+	var response core.ReceiptResponse
+
+	err := c.call(ctx, "Purchase", &core.PurchaseRequest{Name: name, Kgs: kgs}, &response, &response.Status)
+	return response.Receipt, err
+}
+
+// PlaceOrder buys every line of an order or none of them.
+// When the order is rejected the returned *Error holds the
+// reason for every failing line.
+func (c *Client) PlaceOrder(ctx context.Context, lines []core.OrderLine) (core.Receipt, error) {
+	var response core.ReceiptResponse
+
+	err := c.call(ctx, "PlaceOrder", &core.OrderRequest{Lines: lines}, &response, &response.Status)
+
+	var e *Error
+	if errors.As(err, &e) {
+		e.LineErrors = response.LineErrors
+	}
+
+	return response.Receipt, err
 }
@@ -0,0 +1,700 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/menu"
+	"github.com/dimalkavindu/go-rpc/render"
+)
+
+// failure explains why a call failed, as the error a menu
+// command returns. The code and the rejected lines of a
+// failure from the server are kept for the renderer.
+func failure(err error) error {
+	switch err {
+	case context.DeadlineExceeded:
+		return errors.New("The request timed out!")
+	case context.Canceled:
+		return errors.New("The request is cancelled!")
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		return errors.New("The request failed! " + err.Error())
+	}
+
+	f := &render.Error{Code: e.Code.String(), Message: e.Message}
+	if len(e.LineErrors) > 0 {
+		details := render.LineErrors(e.LineErrors)
+		f.Details = &details
+	}
+
+	return f
+}
+
+func (c *Client) showVegitable(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	v, err := c.GetVegitable(ctx, args[0])
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Table(render.Vegitables([]core.Vegitable{v}))
+	return nil
+}
+
+func (c *Client) showAllVegitables(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	vegitables, err := c.ListVegitables(ctx)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Table(render.Vegitables(vegitables))
+	return nil
+}
+
+func (c *Client) showPrice(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	price, err := c.GetPrice(ctx, args[0])
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Table(render.Table{
+		Columns: []render.Column{render.NameColumn, render.PriceColumn},
+		Rows:    [][]string{{args[0], price.String()}},
+	})
+
+	return nil
+}
+
+func (c *Client) showStocks(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	stocks, err := c.GetStocks(ctx, args[0])
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Table(render.Table{
+		Columns: []render.Column{render.NameColumn, render.StocksColumn},
+		Rows:    [][]string{{args[0], stocks.String()}},
+	})
+
+	return nil
+}
+
+func (c *Client) addVegitable(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	price, err := core.ParseMoney(args[1])
+	if err != nil {
+		return errors.New("Invalid unit price '" + args[1] + "'!")
+	}
+
+	stocks, err := core.ParseWeight(args[2])
+	if err != nil {
+		return errors.New("Invalid stocks(KG) '" + args[2] + "'!")
+	}
+
+	v, err := c.AddVegitable(ctx, core.Vegitable{Name: args[0], PricePerKg: price, RemainingKgs: stocks})
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Vegitable '" + v.Name + "' is added successfully!")
+	return nil
+}
+
+// parseVersion reads the optional version a change is
+// guarded with, 0 when it is not given.
+func parseVersion(args []string, i int) (uint64, error) {
+	if len(args) <= i {
+		return 0, nil
+	}
+
+	v, err := strconv.ParseUint(args[i], 10, 64)
+	if err != nil || v == 0 {
+		return 0, errors.New("Invalid version '" + args[i] + "'!")
+	}
+
+	return v, nil
+}
+
+func (c *Client) updatePrice(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	price, err := core.ParseMoney(args[1])
+	if err != nil {
+		return errors.New("Invalid unit price '" + args[1] + "'!")
+	}
+
+	// the optional version guards against overwriting
+	// a change made since it was shown.
+	ver, err := parseVersion(args, 2)
+	if err != nil {
+		return err
+	}
+
+	vegitable, err := c.UpdatePriceIfVersion(ctx, args[0], price, ver)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Vegitable '" + args[0] + "' is updated successfully! It is at version " + strconv.FormatUint(vegitable.Version, 10) + " now.")
+	return nil
+}
+
+func (c *Client) updateStocks(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	stocks, err := core.ParseWeight(args[1])
+	if err != nil {
+		return errors.New("Invalid stocks(KG) '" + args[1] + "'!")
+	}
+
+	ver, err := parseVersion(args, 2)
+	if err != nil {
+		return err
+	}
+
+	vegitable, err := c.UpdateStocksIfVersion(ctx, args[0], stocks, ver)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Vegitable '" + args[0] + "' is updated successfully! It is at version " + strconv.FormatUint(vegitable.Version, 10) + " now.")
+	return nil
+}
+
+func (c *Client) deleteVegitable(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	ver, err := parseVersion(args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.DeleteVegitableIfVersion(ctx, args[0], ver)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Vegitable '" + args[0] + "' is deleted successfully!")
+	return nil
+}
+
+func (c *Client) renameVegitable(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	ver, err := parseVersion(args, 2)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.RenameVegitableIfVersion(ctx, args[0], args[1], ver)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Vegitable '" + args[0] + "' is renamed to '" + args[1] + "' successfully!")
+	return nil
+}
+
+func (c *Client) buyVegitable(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	kgs, err := core.ParseWeight(args[1])
+	if err != nil || kgs <= 0 {
+		return errors.New("Invalid quantity(KG) '" + args[1] + "'!")
+	}
+
+	receipt, err := c.Purchase(ctx, args[0], kgs)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("Purchased " + kgs.String() + " KG of '" + args[0] + "' successfully!")
+	c.out.Table(render.Receipt(receipt))
+	return nil
+}
+
+func (c *Client) addToCart(args ...string) error {
+	kgs, err := core.ParseWeight(args[1])
+	if err != nil || kgs <= 0 {
+		return errors.New("Invalid quantity(KG) '" + args[1] + "'!")
+	}
+
+	for i := range c.cart {
+		if c.cart[i].Name == args[0] {
+			total, err := c.cart[i].Kgs.Plus(kgs)
+			if err != nil {
+				return errors.New("The quantity of '" + args[0] + "' in the cart is too large!")
+			}
+
+			c.cart[i].Kgs = total
+			c.out.Message("Vegitable '" + args[0] + "' is updated in the cart!")
+			return nil
+		}
+	}
+
+	c.cart = append(c.cart, core.OrderLine{Name: args[0], Kgs: kgs})
+	c.out.Message("Vegitable '" + args[0] + "' is added to the cart!")
+	return nil
+}
+
+func (c *Client) removeFromCart(args ...string) error {
+	for i := range c.cart {
+		if c.cart[i].Name == args[0] {
+			c.cart = append(c.cart[:i], c.cart[i+1:]...)
+			c.out.Message("Vegitable '" + args[0] + "' is removed from the cart!")
+			return nil
+		}
+	}
+
+	return errors.New("Vegitable '" + args[0] + "' is not in the cart!")
+}
+
+func (c *Client) showCart(args ...string) error {
+	if len(c.cart) == 0 {
+		c.out.Message("The cart is empty!")
+		return nil
+	}
+
+	table := render.Table{Columns: []render.Column{render.NameColumn, render.QuantityColumn}}
+	for _, l := range c.cart {
+		table.Rows = append(table.Rows, []string{l.Name, l.Kgs.String()})
+	}
+	c.out.Table(table)
+
+	return nil
+}
+
+func (c *Client) checkout(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	if len(c.cart) == 0 {
+		return errors.New("The cart is empty!")
+	}
+
+	receipt, err := c.PlaceOrder(ctx, c.cart)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Message("The order of " + strconv.Itoa(len(receipt.Lines)) + " line(s) is placed successfully!")
+	c.cart = nil
+	c.out.Table(render.Receipt(receipt))
+	return nil
+}
+
+func (c *Client) showHistory(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	entries, err := c.History(ctx, args[0])
+	if err != nil {
+		return failure(err)
+	}
+
+	c.out.Table(render.AuditEntries(entries))
+	return nil
+}
+
+func (c *Client) showPrices(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	var revisions []core.Revision
+
+	if len(args) == 2 {
+		at, err := parseTime(args[1])
+		if err != nil {
+			return errors.New("Invalid time '" + args[1] + "'!")
+		}
+
+		revision, err := c.PriceAt(ctx, args[0], at)
+		if err != nil {
+			return failure(err)
+		}
+
+		revisions = append(revisions, revision)
+	} else {
+		all, err := c.PriceHistory(ctx, args[0])
+		if err != nil {
+			return failure(err)
+		}
+
+		revisions = all
+	}
+
+	table := render.Table{Columns: []render.Column{{Title: "Since", Key: "since"}, render.PriceColumn, render.StocksColumn}}
+
+	for _, r := range revisions {
+		since := "(first record)"
+		if !r.Time.IsZero() {
+			since = r.Time.Local().Format("2006-01-02 15:04:05")
+		}
+
+		if r.Deleted {
+			table.Rows = append(table.Rows, []string{since, "(deleted)", "(deleted)"})
+			continue
+		}
+
+		table.Rows = append(table.Rows, []string{since, r.PricePerKg.String(), r.RemainingKgs.String()})
+	}
+	c.out.Table(table)
+
+	return nil
+}
+
+// instant accepts the times parseTime reads.
+var instant = menu.Type{Name: "time (2006-01-02, 2006-01-02T15:04 or RFC 3339)", Check: func(value string) error {
+	_, err := parseTime(value)
+	return err
+}}
+
+// parseTime reads a time in the local time zone. A bare
+// date stands for the end of that day.
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return t, err
+	}
+
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// watchWait is how long a single Watch call of the
+// 'watch' command waits for changes.
+const watchWait = 20 * time.Second
+
+func (c *Client) watchVegitables(args ...string) error {
+	// the command runs until it is interrupted, only a
+	// single call can time out.
+	ctx, done := c.interruptible(0)
+	defer done()
+
+	slack := c.Timeout
+	if slack <= 0 {
+		slack = 10 * time.Second
+	}
+
+	var (
+		vegitables []core.Vegitable
+		last       string
+		epoch      int64
+		next       uint64
+	)
+
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, watchWait+slack)
+		res, err := c.Watch(callCtx, epoch, next, watchWait)
+		cancel()
+
+		if ctx.Err() != nil {
+			c.out.Message("Stopped watching.")
+			return nil
+		}
+		if err != nil {
+			return failure(err)
+		}
+
+		if res.Reset {
+			vegitables = res.Vegitables
+			last = "-"
+		}
+		for _, e := range res.Events {
+			vegitables = applyEvent(vegitables, e)
+			last = e.Time.Local().Format("15:04:05") + " " + e.Action + " '" + eventName(e) + "'"
+		}
+
+		epoch, next = res.Epoch, res.Next
+
+		if !res.Reset && len(res.Events) == 0 {
+			continue
+		}
+
+		// other programs get every state of the table
+		// in turn, people see it updated in place.
+		if c.out.Structured() {
+			c.out.Table(render.Vegitables(vegitables))
+			continue
+		}
+
+		// clear the screen and draw the table again.
+		fmt.Print("\033[H\033[2J")
+		fmt.Println("Watching the vegitables, press Ctrl-C to stop. Last change: " + last)
+		c.out.Table(render.Vegitables(vegitables))
+	}
+}
+
+// applyEvent updates the vegitables with a change, a
+// renamed vegitable keeps its place.
+func applyEvent(vegitables []core.Vegitable, e core.Event) []core.Vegitable {
+	i := -1
+	if e.Old != nil {
+		for j := range vegitables {
+			if vegitables[j].Name == e.Old.Name {
+				i = j
+				break
+			}
+		}
+	}
+
+	switch {
+	case e.New == nil && i >= 0:
+		return append(vegitables[:i], vegitables[i+1:]...)
+	case e.New == nil:
+		return vegitables
+	case i >= 0:
+		vegitables[i] = *e.New
+		return vegitables
+	}
+
+	return append(vegitables, *e.New)
+}
+
+func eventName(e core.Event) string {
+	if e.New != nil {
+		return e.New.Name
+	}
+
+	return e.Old.Name
+}
+
+func (c *Client) login(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	res, err := c.Login(ctx, args[0])
+	if err != nil {
+		return failure(err)
+	}
+
+	// later logins after a reconnect use the new token.
+	c.Token = args[0]
+	c.setRole(res.Role)
+
+	c.out.Message(res.Message)
+	return nil
+}
+
+func (c *Client) whoAmI(args ...string) error {
+	ctx, done := c.commandContext()
+	defer done()
+
+	res, err := c.WhoAmI(ctx)
+	if err != nil {
+		return failure(err)
+	}
+
+	c.setRole(res.Role)
+
+	c.out.Message(res.Message)
+	return nil
+}
+
+// Start runs the interactive menu until the user exits.
+func (c *Client) Start() (err error) {
+	c.buildMenu()
+	c.menu.Start()
+
+	return
+}
+
+// Run executes a single menu command, e.g. `show price
+// Beans`, and returns an error when it fails. The error is
+// written in the output format as well.
+func (c *Client) Run(cmd ...string) error {
+	c.buildMenu()
+
+	err := c.menu.Run(cmd...)
+	if err != nil {
+		c.out.Error(err)
+	}
+
+	return err
+}
+
+// buildMenu sets up the menu with the commands the role of
+// the session may use.
+func (c *Client) buildMenu() {
+	var (
+		name     = menu.Arg{Name: "vegitable name", Complete: c.vegitableNames}
+		price    = menu.Arg{Name: "unit price", Type: menu.Decimal}
+		stocks   = menu.Arg{Name: "stocks(KG)", Type: menu.Decimal}
+		quantity = menu.Arg{Name: "quantity(KG)", Type: menu.Decimal}
+		version  = menu.Arg{Name: "version", Type: menu.Number, Optional: true}
+	)
+
+	c.out = render.New(os.Stdout, c.Output)
+
+	c.commands = []menu.CommandOption{
+		{Command: "show", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Shows unit price and stocks of a given vegitable", Args: []menu.Arg{name}, Function: c.showVegitable, Subcommands: []menu.CommandOption{
+				{Command: "all", Description: "Shows all the vegitables", Function: c.showAllVegitables},
+			}},
+			{Command: "price", Description: "Shows the unit price of a given vegitable", Args: []menu.Arg{name}, Function: c.showPrice},
+			{Command: "stocks", Description: "Shows the stocks of a given vegitable", Args: []menu.Arg{name}, Function: c.showStocks},
+		}},
+		{Command: "add", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Adds a new vegitable with a given unit price & a stock value in KG", Args: []menu.Arg{name, price, stocks}, Function: c.addVegitable},
+		}},
+		{Command: "update", Subcommands: []menu.CommandOption{
+			{Command: "price", Description: "Updates the unit price of a given vegitable, only if it is still at the version when given", Args: []menu.Arg{name, price, version}, Function: c.updatePrice},
+			{Command: "stocks", Description: "Updates the stocks of a given vegitable, only if it is still at the version when given", Args: []menu.Arg{name, stocks, version}, Function: c.updateStocks},
+		}},
+		{Command: "delete", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Deletes a given vegitable, only if it is still at the version when given", Args: []menu.Arg{name, version}, Function: c.deleteVegitable},
+		}},
+		{Command: "rename", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Renames a given vegitable keeping its unit price & stocks, only if it is still at the version when given", Args: []menu.Arg{name, {Name: "new name"}, version}, Function: c.renameVegitable},
+		}},
+		{Command: "buy", Description: "Buys a quantity of a given vegitable and shows the receipt", Args: []menu.Arg{name, quantity}, Function: c.buyVegitable},
+		{Command: "cart", Subcommands: []menu.CommandOption{
+			{Command: "add", Description: "Adds a quantity of a given vegitable to the cart", Args: []menu.Arg{name, quantity}, Function: c.addToCart},
+			{Command: "remove", Description: "Removes a given vegitable from the cart", Args: []menu.Arg{{Name: "vegitable name", Complete: c.cartNames}}, Function: c.removeFromCart},
+			{Command: "show", Description: "Shows the vegitables in the cart", Function: c.showCart},
+			{Command: "checkout", Description: "Buys everything in the cart as a single order", Function: c.checkout},
+		}},
+		{Command: "history", Description: "Shows who changed a given vegitable, when and how", Args: []menu.Arg{name}, Function: c.showHistory},
+		{Command: "prices", Description: "Shows every change of the unit price & stocks of a given vegitable, or the ones at a given time", Args: []menu.Arg{name, {Name: "time", Type: instant, Optional: true}}, Function: c.showPrices},
+		{Command: "watch", Description: "Shows all the vegitables and keeps the table up to date as they change, until Ctrl-C", Function: c.watchVegitables},
+		{Command: "login", Description: "Logs in with a given API token", Args: []menu.Arg{{Name: "token", Secret: true}}, Function: c.login},
+		{Command: "whoami", Description: "Shows who the client is logged in as", Function: c.whoAmI},
+	}
+
+	// the session of Init decides what is shown, without
+	// one only login is of any use.
+	ctx, done := c.commandContext()
+	if res, err := c.WhoAmI(ctx); err == nil {
+		c.role = res.Role
+	}
+	done()
+
+	menuOptions := menu.NewMenuOptions("'menu' for help > ", 500)
+	menuOptions.HistoryFile = c.HistoryFile
+	menuOptions.Renderer = c.out
+
+	c.menu = menu.NewMenu(c.allowed(), menuOptions)
+}
+
+// completionTimeout bounds the call listing the
+// vegitables for a Tab in the menu.
+const completionTimeout = 2 * time.Second
+
+// vegitableNames asks the server for the vegitables for
+// the menu to complete names with, none when it fails.
+func (c *Client) vegitableNames(prefix string) (names []string) {
+	ctx, done := context.WithTimeout(context.Background(), completionTimeout)
+	defer done()
+
+	vegitables, err := c.ListVegitables(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, v := range vegitables {
+		names = append(names, v.Name)
+	}
+
+	return
+}
+
+// cartNames lists the vegitables in the cart.
+func (c *Client) cartNames(prefix string) (names []string) {
+	for _, l := range c.cart {
+		names = append(names, l.Name)
+	}
+
+	return
+}
+
+// commandMethods lists the V1 methods behind each menu
+// command, by the words leading to it. A command is shown
+// when the role may call any of them, one with none of
+// its subcommands left is not.
+var commandMethods = map[string][]string{
+	"show":          {"ListVegitables", "GetVegitable"},
+	"add":           {"AddVegitable"},
+	"update price":  {"UpdatePrice"},
+	"update stocks": {"UpdateStocks"},
+	"delete":        {"DeleteVegitable"},
+	"rename":        {"RenameVegitable"},
+	"buy":           {"Purchase"},
+	"cart":          {"PlaceOrder"},
+	"history":       {"History"},
+	"prices":        {"PriceHistory", "PriceAt"},
+	"watch":         {"Watch"},
+}
+
+// allowed returns the menu commands the role of the
+// session may use.
+func (c *Client) allowed() []menu.CommandOption {
+	return c.permitted(c.commands, "")
+}
+
+func (c *Client) permitted(commands []menu.CommandOption, prefix string) (permitted []menu.CommandOption) {
+	for _, command := range commands {
+		path := prefix + command.Command
+
+		if methods, ok := commandMethods[path]; ok {
+			can := false
+			for _, method := range methods {
+				can = can || c.role.Can(method)
+			}
+			if !can {
+				continue
+			}
+		}
+
+		if len(command.Subcommands) > 0 {
+			command.Subcommands = c.permitted(command.Subcommands, path+" ")
+			if len(command.Subcommands) == 0 && command.Function == nil {
+				continue
+			}
+		}
+
+		permitted = append(permitted, command)
+	}
+
+	return
+}
+
+// setRole shows the menu commands of a new role.
+func (c *Client) setRole(role core.Role) {
+	c.role = role
+	if c.menu != nil {
+		c.menu.Commands = c.allowed()
+	}
+}
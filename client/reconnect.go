@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/rpc"
+	"time"
+)
+
+const (
+	// DefaultRetries is the number of redials used
+	// when Client.Retries is zero.
+	DefaultRetries = 5
+
+	// DefaultBackoffBase and DefaultBackoffMax bound
+	// the delay between redials when the Client does
+	// not set them.
+	DefaultBackoffBase = 100 * time.Millisecond
+	DefaultBackoffMax  = 5 * time.Second
+)
+
+// idempotent lists the V1 methods that can safely be sent
+// again after the connection broke while waiting for
+// their response.
+var idempotent = map[string]bool{
+	"ListVegitables": true,
+	"GetVegitable":   true,
+	"GetPrice":       true,
+	"GetStocks":      true,
+	"History":        true,
+	"PriceAt":        true,
+	"PriceHistory":   true,
+	"Watch":          true,
+}
+
+// isBroken tells whether err means the connection is
+// gone, as opposed to an error returned by the server.
+func isBroken(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (c *Client) retries() int {
+	if c.Retries == 0 {
+		return DefaultRetries
+	}
+	if c.Retries < 0 {
+		return 0
+	}
+
+	return c.Retries
+}
+
+// backoff returns the delay before the given redial
+// attempt: a random duration up to BackoffBase*2^attempt,
+// capped at BackoffMax ("full jitter"), so that clients
+// disconnected together do not redial in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	base, max := c.BackoffBase, c.BackoffMax
+	if base <= 0 {
+		base = DefaultBackoffBase
+	}
+	if max <= 0 {
+		max = DefaultBackoffMax
+	}
+
+	ceiling := max
+	if attempt < 32 && base<<uint(attempt) < max {
+		ceiling = base << uint(attempt)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.random == nil {
+		c.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return time.Duration(c.random.Int63n(int64(ceiling) + 1))
+}
+
+// reconnect replaces the broken connection with a new one
+// dialed in the same mode, waiting a jittered exponential
+// backoff between failed attempts. It gives up once the
+// retries are exhausted or ctx is done.
+//
+// Concurrent callers that saw the same broken connection
+// share a single redial.
+func (c *Client) reconnect(ctx context.Context, broken *rpc.Client) (err error) {
+	c.dialMutex.Lock()
+	defer c.dialMutex.Unlock()
+
+	c.mutex.Lock()
+	if c.closed || c.client != broken {
+		c.mutex.Unlock()
+		return
+	}
+	c.mutex.Unlock()
+
+	broken.Close()
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+
+		var conn *rpc.Client
+
+		conn, err = c.dial()
+		if err == nil {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			if c.closed {
+				return conn.Close()
+			}
+
+			c.client = conn
+			return
+		}
+
+		if attempt+1 >= c.retries() {
+			return
+		}
+	}
+}
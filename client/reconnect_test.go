@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// restartingServer is a V1 server that can be restarted
+// under its clients: every connection is closed and the
+// port is listened on again a little later.
+type restartingServer struct {
+	t       *testing.T
+	addr    string
+	mutex   sync.Mutex
+	l       net.Listener
+	conns   []net.Conn
+	calls   map[string]int
+	restart map[string]bool
+}
+
+func serve(t *testing.T) *restartingServer {
+	t.Helper()
+
+	s := &restartingServer{t: t, addr: "127.0.0.1:0", calls: make(map[string]int), restart: make(map[string]bool)}
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	s.addr = s.l.Addr().String()
+	t.Cleanup(s.stop)
+
+	return s
+}
+
+func (s *restartingServer) port() uint {
+	return uint(s.l.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *restartingServer) listen() error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.l = l
+	s.mutex.Unlock()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			s.mutex.Lock()
+			s.conns = append(s.conns, conn)
+			s.mutex.Unlock()
+
+			server := rpc.NewServer()
+			server.RegisterName("V1", &fakeV1{s})
+			go server.ServeConn(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (s *restartingServer) stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.l.Close()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// restartAfter stops the server and listens again after d.
+func (s *restartingServer) restartAfter(d time.Duration) {
+	s.stop()
+
+	time.AfterFunc(d, func() {
+		if err := s.listen(); err != nil {
+			s.t.Error(err)
+		}
+	})
+}
+
+// call counts a call of method, restarting the server
+// instead of answering when asked to once.
+func (s *restartingServer) call(method string) (answer bool) {
+	s.mutex.Lock()
+	s.calls[method]++
+	restart := s.restart[method]
+	s.restart[method] = false
+	s.mutex.Unlock()
+
+	if restart {
+		s.restartAfter(200 * time.Millisecond)
+	}
+
+	return !restart
+}
+
+func (s *restartingServer) count(method string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.calls[method]
+}
+
+func (s *restartingServer) restartOn(method string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.restart[method] = true
+}
+
+type fakeV1 struct {
+	s *restartingServer
+}
+
+func (f *fakeV1) ListVegitables(req core.ListVegitablesRequest, res *core.VegitablesResponse) error {
+	if f.s.call("ListVegitables") {
+		res.Status = core.Status{Ok: true}
+		res.Vegitables = []core.Vegitable{{Name: "Beans", PricePerKg: 17500, RemainingKgs: 10100}}
+	}
+
+	return nil
+}
+
+func (f *fakeV1) AddVegitable(req core.AddVegitableRequest, res *core.VegitableResponse) error {
+	if f.s.call("AddVegitable") {
+		res.Status = core.Status{Ok: true}
+		res.Vegitable = req.Vegitable
+	}
+
+	return nil
+}
+
+func connect(t *testing.T, s *restartingServer) *Client {
+	t.Helper()
+
+	c := &Client{Port: s.port(), Retries: 100, BackoffBase: 10 * time.Millisecond, BackoffMax: 50 * time.Millisecond}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func list(t *testing.T, c *Client) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	vegitables, err := c.ListVegitables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vegitables) != 1 || vegitables[0].Name != "Beans" {
+		t.Fatalf("got %+v, want Beans", vegitables)
+	}
+}
+
+// A read goes on once the server is back, whether the
+// connection broke before it was sent or while the server
+// had it.
+func TestReconnectRetriesReads(t *testing.T) {
+	s := serve(t)
+	c := connect(t, s)
+
+	list(t, c)
+
+	s.restartAfter(200 * time.Millisecond)
+	list(t, c)
+
+	s.restartOn("ListVegitables")
+	list(t, c)
+
+	if got := s.count("ListVegitables"); got != 4 {
+		t.Fatalf("the server got %d reads, want 4", got)
+	}
+}
+
+// A mutation the server may have applied is not sent
+// again once the server is back, the connection is.
+func TestReconnectDoesNotRetryMutations(t *testing.T) {
+	s := serve(t)
+	c := connect(t, s)
+
+	s.restartOn("AddVegitable")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.AddVegitable(ctx, core.Vegitable{Name: "Carrot", PricePerKg: 9000}); !isBroken(err) {
+		t.Fatalf("got %v, want the broken connection", err)
+	}
+	if got := s.count("AddVegitable"); got != 1 {
+		t.Fatalf("the server got the mutation %d times, want once", got)
+	}
+
+	list(t, c)
+}
@@ -0,0 +1,31 @@
+package core
+
+import "time"
+
+// AuditEntry records a single change of the inventory:
+// who made it, through which connection and what the
+// vegitable looked like before and after. Old is nil for
+// additions and New is nil for deletions.
+type AuditEntry struct {
+	Time       time.Time
+	Actor      string
+	Role       Role
+	Connection string
+	Action     string
+	Name       string
+	Old        *Vegitable `json:",omitempty"`
+	New        *Vegitable `json:",omitempty"`
+}
+
+// HistoryRequest asks for the audit entries of a
+// vegitable, including the ones recorded under the
+// names it had before being renamed.
+type HistoryRequest struct {
+	Credentials
+	Name string
+}
+
+type HistoryResponse struct {
+	Status
+	Entries []AuditEntry
+}
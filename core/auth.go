@@ -0,0 +1,94 @@
+package core
+
+// Role decides which operations a session may perform.
+// Every role can do everything the roles before it can:
+//
+//	customer   read the inventory and buy
+//	clerk      update stocks, read the audit log and the
+//	           price history
+//	admin      add, delete, rename and update prices
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleClerk    Role = "clerk"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleCustomer: 1,
+	RoleClerk:    2,
+	RoleAdmin:    3,
+}
+
+// permissions maps every V1 method that needs a role to
+// the least role allowed to call it. A method missing here
+// is denied to every role, the read-only ones included.
+var permissions = map[string]Role{
+	// reading the inventory
+	"ListVegitables": RoleCustomer,
+	"GetVegitable":   RoleCustomer,
+	"GetPrice":       RoleCustomer,
+	"GetStocks":      RoleCustomer,
+	"Watch":          RoleCustomer,
+
+	"Purchase":        RoleCustomer,
+	"PlaceOrder":      RoleCustomer,
+	"UpdateStocks":    RoleClerk,
+	"History":         RoleClerk,
+	"PriceAt":         RoleClerk,
+	"PriceHistory":    RoleClerk,
+	"AddVegitable":    RoleAdmin,
+	"UpdatePrice":     RoleAdmin,
+	"DeleteVegitable": RoleAdmin,
+	"RenameVegitable": RoleAdmin,
+}
+
+// Valid tells whether r is one of the known roles.
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Can tells whether the role may call the V1 method.
+// Methods without a required role are denied, so a method
+// added later stays closed until it is given one.
+func (r Role) Can(method string) bool {
+	required, ok := permissions[method]
+	if !ok {
+		return false
+	}
+
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Credentials is embedded in every typed request that
+// needs a role. Session is the token handed out by
+// V1.Login.
+type Credentials struct {
+	Session string
+}
+
+// SetSession lets the client attach its session to any
+// request embedding Credentials.
+func (c *Credentials) SetSession(session string) {
+	c.Session = session
+}
+
+// LoginRequest exchanges an API token for a session.
+type LoginRequest struct {
+	Token string
+}
+
+type WhoAmIRequest struct {
+	Credentials
+}
+
+// SessionResponse describes a session. When the server
+// has authentication disabled everyone is an anonymous
+// admin.
+type SessionResponse struct {
+	Status
+	Session string
+	Name    string
+	Role    Role
+}
@@ -0,0 +1,40 @@
+package core
+
+import "testing"
+
+func TestRoleCan(t *testing.T) {
+	tests := []struct {
+		role   Role
+		method string
+		want   bool
+	}{
+		{RoleCustomer, "ListVegitables", true},
+		{RoleCustomer, "Watch", true},
+		{RoleCustomer, "PlaceOrder", true},
+		{RoleCustomer, "UpdateStocks", false},
+		{RoleCustomer, "History", false},
+		{RoleClerk, "UpdateStocks", true},
+		{RoleClerk, "PriceHistory", true},
+		{RoleClerk, "UpdatePrice", false},
+		{RoleClerk, "DeleteVegitable", false},
+		{RoleAdmin, "RenameVegitable", true},
+		{RoleAdmin, "GetStocks", true},
+		{"", "ListVegitables", false},
+		{"owner", "ListVegitables", false},
+	}
+
+	for _, test := range tests {
+		if got := test.role.Can(test.method); got != test.want {
+			t.Errorf("%q.Can(%q) = %v, want %v", test.role, test.method, got, test.want)
+		}
+	}
+}
+
+// A method nobody gave a role to is closed to everyone.
+func TestRoleCanDeniesUnknownMethods(t *testing.T) {
+	for _, role := range []Role{RoleCustomer, RoleClerk, RoleAdmin} {
+		if role.Can("DropInventory") {
+			t.Errorf("%q may call a method missing from the permissions", role)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrorCode is a stable, machine-readable reason for the
+// outcome of an operation, so integrations do not have to
+// match the English text of Message.
+//
+// The numeric values are part of the wire format and must
+// never be reused; codes are encoded as their names in
+// JSON.
+type ErrorCode int
+
+const (
+	CodeOK                ErrorCode = 0
+	CodeNotFound          ErrorCode = 1
+	CodeAlreadyExists     ErrorCode = 2
+	CodeInvalidArgument   ErrorCode = 3
+	CodeInsufficientStock ErrorCode = 4
+	CodeUnauthorized      ErrorCode = 5
+	CodeConflict          ErrorCode = 6
+)
+
+var codeNames = map[ErrorCode]string{
+	CodeOK:                "OK",
+	CodeNotFound:          "NotFound",
+	CodeAlreadyExists:     "AlreadyExists",
+	CodeInvalidArgument:   "InvalidArgument",
+	CodeInsufficientStock: "InsufficientStock",
+	CodeUnauthorized:      "Unauthorized",
+	CodeConflict:          "Conflict",
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+
+	return "ErrorCode(" + strconv.Itoa(int(c)) + ")"
+}
+
+func (c ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *ErrorCode) UnmarshalText(text []byte) error {
+	for code, name := range codeNames {
+		if name == string(text) {
+			*c = code
+			return nil
+		}
+	}
+
+	return errors.New("unknown error code '" + string(text) + "'")
+}
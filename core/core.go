@@ -9,31 +9,155 @@ package core
 
 import (
 	"encoding/xml"
+	"time"
 )
 
+// Response is the answer of a legacy string command, see
+// server.Handler.
 type Response struct {
 	Ok         bool
+	Code       ErrorCode
 	Message    string
-	Vegitables Vegitables
+	Vegitables LegacyVegitables
+	Receipt    Receipt
+	LineErrors []OrderLineError
 }
 
+// LegacyVegitables are the vegitables of a legacy
+// Response. Their amounts are the text they always were
+// (e.g. "175.00") rather than decimals, which gob encodes
+// differently, so clients built before the decimals still
+// decode them.
+type LegacyVegitables struct {
+	Vegitables []LegacyVegitable
+}
+
+type LegacyVegitable struct {
+	Name         string
+	PricePerKg   string
+	RemainingKgs string
+	Version      uint64
+}
+
+// Legacy converts vegitables for a legacy Response.
+func Legacy(vegitables ...Vegitable) (l LegacyVegitables) {
+	for _, v := range vegitables {
+		l.Vegitables = append(l.Vegitables, LegacyVegitable{
+			Name:         v.Name,
+			PricePerKg:   v.PricePerKg.String(),
+			RemainingKgs: v.RemainingKgs.String(),
+			Version:      v.Version,
+		})
+	}
+
+	return
+}
+
+// Vegitable parses the amounts of a legacy vegitable
+// back.
+func (l LegacyVegitable) Vegitable() (v Vegitable, err error) {
+	v = Vegitable{Name: l.Name, Version: l.Version}
+
+	v.PricePerKg, err = ParseMoney(l.PricePerKg)
+	if err != nil {
+		return
+	}
+
+	v.RemainingKgs, err = ParseWeight(l.RemainingKgs)
+	return
+}
+
+// Request is a legacy string command, see
+// server.Handler. Credentials carry the session when the
+// server requires a login.
 type Request struct {
+	Credentials
 	Command []string
 }
 
+// OrderRequest asks the server to buy several
+// vegitables at once. The order is either fulfilled
+// completely or rejected as a whole.
+type OrderRequest struct {
+	Credentials
+	Lines []OrderLine
+}
+
+// A single vegitable and the quantity to buy of it.
+type OrderLine struct {
+	Name string
+	Kgs  Weight
+}
+
+// OrderLineError explains why a line of an order
+// could not be fulfilled. Line is the zero-based
+// position of the line in the order.
+type OrderLineError struct {
+	Line   int
+	Name   string
+	Code   ErrorCode
+	Reason string
+}
+
 // A struct which contains the complete
 // array of all vegitables in the file
+//
+// History holds the price and stock revisions of every
+// vegitable, including the deleted ones.
 type Vegitables struct {
-	XMLName    xml.Name    `xml:"vegitables"`
+	XMLName    xml.Name    `xml:"vegitables" json:"-"`
 	Vegitables []Vegitable `xml:"vegitable"`
+	History    []History   `xml:"history>vegitable,omitempty" json:",omitempty"`
 }
 
 // the vegitable struct, this contains
 // vegitable name name, price per kg and
 // remaining kgs
+//
+// Both amounts are fixed-point decimals that encode
+// to the same text the file always had (e.g. "175.00").
+//
+// Version is increased by the store on every change of
+// the vegitable, starting at 1.
 type Vegitable struct {
-	XMLName      xml.Name `xml:"vegitable"`
+	XMLName      xml.Name `xml:"vegitable" json:"-"`
 	Name         string   `xml:"name"`
-	PricePerKg   string   `xml:"pricePerKg"`
-	RemainingKgs string   `xml:"remainingKgs"`
+	PricePerKg   Money    `xml:"pricePerKg"`
+	RemainingKgs Weight   `xml:"remainingKgs"`
+	Version      uint64   `xml:"version,omitempty"`
+}
+
+// History is the series of revisions of the vegitable
+// called Name, oldest first.
+type History struct {
+	Name      string     `xml:"name,attr"`
+	Revisions []Revision `xml:"revision"`
+}
+
+// Revision is the unit price and stocks a vegitable had
+// from Time on, up to the next revision. A zero Time
+// stands for the state the vegitable was in when history
+// keeping started. Deleted marks the vegitable being
+// deleted at Time.
+type Revision struct {
+	Time         time.Time `xml:"time,attr"`
+	Deleted      bool      `xml:"deleted,attr,omitempty" json:",omitempty"`
+	PricePerKg   Money     `xml:"pricePerKg"`
+	RemainingKgs Weight    `xml:"remainingKgs"`
+}
+
+// Receipt is handed back to the client after a
+// successful purchase.
+type Receipt struct {
+	Lines []ReceiptLine
+	Total Money
+}
+
+// A single vegitable bought as part of a purchase, with
+// the unit price it was sold at.
+type ReceiptLine struct {
+	Name       string
+	Kgs        Weight
+	PricePerKg Money
+	Amount     Money
 }
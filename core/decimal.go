@@ -0,0 +1,196 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of money stored as a fixed-point
+// decimal with two fractional digits (i.e. in cents) so
+// that arithmetic is exact.
+type Money int64
+
+// Weight is a weight stored as a fixed-point decimal in
+// kilograms with three fractional digits (i.e. in grams).
+type Weight int64
+
+const (
+	moneyScale  = 2
+	weightScale = 3
+)
+
+// ErrInvalidDecimal is returned when a string is not a
+// valid decimal number for the requested precision.
+var ErrInvalidDecimal = errors.New("invalid decimal number")
+
+// ErrOverflow is returned when the result of arithmetic
+// on decimals is too large to be represented.
+var ErrOverflow = errors.New("decimal overflow")
+
+// ParseMoney parses amounts such as "175", "175.5" or
+// "175.50".
+func ParseMoney(s string) (Money, error) {
+	v, err := parseFixed(s, moneyScale)
+	return Money(v), err
+}
+
+// ParseWeight parses weights in kilograms such as "10",
+// "10.1" or "10.100".
+func ParseWeight(s string) (Weight, error) {
+	v, err := parseFixed(s, weightScale)
+	return Weight(v), err
+}
+
+func (m Money) String() string {
+	return formatFixed(int64(m), moneyScale)
+}
+
+func (m Money) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalText(text []byte) (err error) {
+	*m, err = ParseMoney(string(text))
+	return
+}
+
+// Times returns the price of w kilograms at m per
+// kilogram, rounded half up to the nearest cent. It fails
+// with ErrOverflow when the price does not fit in Money.
+func (m Money) Times(w Weight) (Money, error) {
+	const grams = 1000
+
+	// the product of the magnitudes takes 128 bits, it
+	// fits once divided by the grams in a kilogram
+	hi, lo := bits.Mul64(magnitude(int64(m)), magnitude(int64(w)))
+	lo, carry := bits.Add64(lo, grams/2, 0)
+	hi += carry
+
+	if hi >= grams {
+		return 0, ErrOverflow
+	}
+
+	total, _ := bits.Div64(hi, lo, grams)
+	if total > math.MaxInt64 {
+		return 0, ErrOverflow
+	}
+
+	if (m < 0) != (w < 0) {
+		return -Money(total), nil
+	}
+
+	return Money(total), nil
+}
+
+// Plus returns m + n, failing with ErrOverflow when the
+// sum does not fit in Money.
+func (m Money) Plus(n Money) (Money, error) {
+	sum := m + n
+	if (n > 0 && sum < m) || (n < 0 && sum > m) {
+		return 0, ErrOverflow
+	}
+
+	return sum, nil
+}
+
+// Plus returns w + n, failing with ErrOverflow when the
+// sum does not fit in Weight.
+func (w Weight) Plus(n Weight) (Weight, error) {
+	sum := w + n
+	if (n > 0 && sum < w) || (n < 0 && sum > w) {
+		return 0, ErrOverflow
+	}
+
+	return sum, nil
+}
+
+func (w Weight) String() string {
+	return formatFixed(int64(w), weightScale)
+}
+
+func (w Weight) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+func (w *Weight) UnmarshalText(text []byte) (err error) {
+	*w, err = ParseWeight(string(text))
+	return
+}
+
+// parseFixed parses s into an integer scaled by 10^scale.
+// Inputs with more fractional digits than scale are
+// rejected rather than silently rounded, and so are more
+// signs than one.
+func parseFixed(s string, scale int) (v int64, err error) {
+	s = strings.TrimSpace(s)
+
+	digits := s
+	negative := strings.HasPrefix(s, "-")
+	if negative || strings.HasPrefix(s, "+") {
+		digits = s[1:]
+	}
+
+	whole, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+	}
+
+	if (whole == "" && fraction == "") || len(fraction) > scale || !isDigits(whole) || !isDigits(fraction) {
+		err = fmt.Errorf("%w '%s'", ErrInvalidDecimal, s)
+		return
+	}
+
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	v, err = strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		err = fmt.Errorf("%w '%s'", ErrInvalidDecimal, s)
+		return
+	}
+
+	if negative {
+		v = -v
+	}
+
+	return
+}
+
+// formatFixed formats an integer scaled by 10^scale with
+// exactly scale fractional digits.
+func formatFixed(v int64, scale int) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+
+	digits := strconv.FormatInt(v, 10)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// magnitude returns the absolute value of v, which fits
+// in an uint64 even for math.MinInt64.
+func magnitude(v int64) uint64 {
+	if v < 0 {
+		return -uint64(v)
+	}
+
+	return uint64(v)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"175", 17500},
+		{"175.5", 17550},
+		{"175.50", 17550},
+		{" 0.05 ", 5},
+		{".5", 50},
+		{"5.", 500},
+		{"+1", 100},
+		{"-1.25", -125},
+	}
+
+	for _, test := range tests {
+		got, err := ParseMoney(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("ParseMoney(%q) = %d, %v, want %d", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Weight
+	}{
+		{"10", 10000},
+		{"10.1", 10100},
+		{"10.100", 10100},
+		{"0.001", 1},
+	}
+
+	for _, test := range tests {
+		got, err := ParseWeight(test.in)
+		if err != nil || got != test.want {
+			t.Errorf("ParseWeight(%q) = %d, %v, want %d", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestParseInvalidDecimal(t *testing.T) {
+	for _, in := range []string{"", ".", "-", "abc", "1.2.3", "1e3", "1,5", "0x10", "1.005", "99999999999999999999", "-+5", "+-5", "--5", "++5", "- 5"} {
+		if _, err := ParseMoney(in); !errors.Is(err, ErrInvalidDecimal) {
+			t.Errorf("ParseMoney(%q) = %v, want ErrInvalidDecimal", in, err)
+		}
+	}
+
+	if _, err := ParseWeight("1.0001"); !errors.Is(err, ErrInvalidDecimal) {
+		t.Errorf("ParseWeight(%q) = %v, want ErrInvalidDecimal", "1.0001", err)
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		got, want string
+	}{
+		{Money(17550).String(), "175.50"},
+		{Money(5).String(), "0.05"},
+		{Money(-125).String(), "-1.25"},
+		{Money(0).String(), "0.00"},
+		{Weight(10100).String(), "10.100"},
+		{Weight(1).String(), "0.001"},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %q, want %q", test.got, test.want)
+		}
+	}
+}
+
+func TestMoneyTimes(t *testing.T) {
+	tests := []struct {
+		m    Money
+		w    Weight
+		want Money
+	}{
+		{17500, 500, 8750},
+		{100, 1, 0},   // 0.1 cent rounds down
+		{100, 5, 1},   // 0.5 cent rounds up
+		{-100, 5, -1}, // half away from zero
+		{333, 3000, 999},
+		{math.MaxInt64 / 1000, 1000, math.MaxInt64 / 1000},
+	}
+
+	for _, test := range tests {
+		got, err := test.m.Times(test.w)
+		if err != nil || got != test.want {
+			t.Errorf("%d.Times(%d) = %d, %v, want %d", test.m, test.w, got, err, test.want)
+		}
+	}
+}
+
+func TestMoneyTimesOverflow(t *testing.T) {
+	for _, w := range []Weight{math.MaxInt64, 1 << 40, -(1 << 40)} {
+		if got, err := Money(1 << 40).Times(w); err != ErrOverflow {
+			t.Errorf("Times(%d) = %d, %v, want ErrOverflow", w, got, err)
+		}
+	}
+}
+
+func TestMoneyPlusOverflow(t *testing.T) {
+	if _, err := Money(math.MaxInt64).Plus(1); err != ErrOverflow {
+		t.Errorf("got %v, want ErrOverflow", err)
+	}
+	if _, err := Money(math.MinInt64).Plus(-1); err != ErrOverflow {
+		t.Errorf("got %v, want ErrOverflow", err)
+	}
+	if sum, err := Money(100).Plus(-250); err != nil || sum != -150 {
+		t.Errorf("got %d, %v, want -150", sum, err)
+	}
+}
+
+func TestWeightPlusOverflow(t *testing.T) {
+	if _, err := Weight(math.MaxInt64).Plus(1); err != ErrOverflow {
+		t.Errorf("got %v, want ErrOverflow", err)
+	}
+	if sum, err := Weight(1500).Plus(2500); err != nil || sum != 4000 {
+		t.Errorf("got %d, %v, want 4000", sum, err)
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+)
+
+// TLSFiles points to the PEM files used to secure the
+// connection between client and server.
+//
+// On the server CertFile/KeyFile hold its certificate and
+// CAFile, when VerifyClient is set, the authority client
+// certificates must be signed by (mutual TLS).
+//
+// On the client CertFile/KeyFile hold the optional client
+// certificate and CAFile the authority the server
+// certificate must be signed by (the system roots are used
+// when it is empty).
+type TLSFiles struct {
+	CertFile     string
+	KeyFile      string
+	CAFile       string
+	VerifyClient bool
+}
+
+// ServerConfig builds the TLS configuration of a server.
+func (f TLSFiles) ServerConfig() (config *tls.Config, err error) {
+	if f.CertFile == "" || f.KeyFile == "" {
+		err = errors.New("tls: the server needs both a certificate and a key")
+		return
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+	if err != nil {
+		return
+	}
+
+	config = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if f.VerifyClient {
+		if f.CAFile == "" {
+			err = errors.New("tls: verifying clients needs a CA")
+			return
+		}
+
+		config.ClientCAs, err = loadPool(f.CAFile)
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return
+}
+
+// ClientConfig builds the TLS configuration of a client
+// connecting to serverName.
+func (f TLSFiles) ClientConfig(serverName string) (config *tls.Config, err error) {
+	config = &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if f.CAFile != "" {
+		config.RootCAs, err = loadPool(f.CAFile)
+		if err != nil {
+			return
+		}
+	}
+
+	if f.CertFile != "" || f.KeyFile != "" {
+		var cert tls.Certificate
+
+		cert, err = tls.LoadX509KeyPair(f.CertFile, f.KeyFile)
+		if err != nil {
+			return
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return
+}
+
+// loadPool reads the PEM certificates in path.
+func loadPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("tls: no certificate found in '" + path + "'")
+	}
+
+	return pool, nil
+}
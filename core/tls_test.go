@@ -0,0 +1,139 @@
+package core
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dimalkavindu/go-rpc/internal/testcert"
+)
+
+// handshake runs a TLS handshake over a pipe and returns
+// the error each side saw.
+func handshake(t *testing.T, server, client *tls.Config) (serverErr, clientErr error) {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		s := tls.Server(serverConn, server)
+		err := s.Handshake()
+		if err == nil {
+			// with TLS 1.3 the client certificate is only
+			// checked once the client reads
+			_, err = s.Write([]byte("ok"))
+		}
+		if err != nil {
+			serverConn.Close()
+		}
+		done <- err
+	}()
+
+	c := tls.Client(clientConn, client)
+	clientErr = c.Handshake()
+	if clientErr == nil {
+		_, clientErr = c.Read(make([]byte, 2))
+	}
+	if clientErr != nil {
+		clientConn.Close()
+	}
+
+	return <-done, clientErr
+}
+
+func TestServerConfigNeedsCertificate(t *testing.T) {
+	files := testcert.Generate(t)
+
+	if _, err := (TLSFiles{CertFile: files.ServerCert}).ServerConfig(); err == nil {
+		t.Error("a server config without a key was built")
+	}
+
+	_, err := (TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey, VerifyClient: true}).ServerConfig()
+	if err == nil || !strings.Contains(err.Error(), "CA") {
+		t.Errorf("got %v, want an error about the missing CA", err)
+	}
+
+	_, err = (TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey, CAFile: files.ServerKey, VerifyClient: true}).ServerConfig()
+	if err == nil {
+		t.Error("a key was accepted as a CA")
+	}
+}
+
+func TestTLS(t *testing.T) {
+	files := testcert.Generate(t)
+
+	server, err := (TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey}).ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ClientAuth != tls.NoClientCert {
+		t.Errorf("client auth is %v without VerifyClient", server.ClientAuth)
+	}
+
+	client, err := (TLSFiles{CAFile: files.CA}).ClientConfig("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if serverErr, clientErr := handshake(t, server, client); serverErr != nil || clientErr != nil {
+		t.Fatalf("handshake failed: server %v, client %v", serverErr, clientErr)
+	}
+
+	// the test CA is not among the system roots
+	untrusting, err := (TLSFiles{}).ClientConfig("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, clientErr := handshake(t, server, untrusting); clientErr == nil {
+		t.Error("a client trusted a server signed by an unknown CA")
+	}
+
+	// nor is the server certificate valid for another name
+	elsewhere, err := (TLSFiles{CAFile: files.CA}).ClientConfig("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, clientErr := handshake(t, server, elsewhere); clientErr == nil {
+		t.Error("a client accepted a certificate of another server")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	files := testcert.Generate(t)
+
+	server, err := (TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey, CAFile: files.CA, VerifyClient: true}).ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("client auth is %v, want RequireAndVerifyClientCert", server.ClientAuth)
+	}
+
+	client, err := (TLSFiles{CertFile: files.ClientCert, KeyFile: files.ClientKey, CAFile: files.CA}).ClientConfig("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Certificates) != 1 {
+		t.Fatalf("got %d client certificates, want 1", len(client.Certificates))
+	}
+
+	if serverErr, clientErr := handshake(t, server, client); serverErr != nil || clientErr != nil {
+		t.Fatalf("handshake failed: server %v, client %v", serverErr, clientErr)
+	}
+
+	anonymous, err := (TLSFiles{CAFile: files.CA}).ClientConfig("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverErr, _ := handshake(t, server, anonymous); serverErr == nil {
+		t.Error("the server accepted a client without a certificate")
+	}
+
+	if _, err := (TLSFiles{CertFile: files.ClientCert, CAFile: files.CA}).ClientConfig("localhost"); err == nil {
+		t.Error("a client certificate without a key was accepted")
+	}
+}
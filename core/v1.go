@@ -0,0 +1,141 @@
+package core
+
+import "time"
+
+// The typed messages of the "V1" RPC namespace. Each
+// operation has its own request struct so that the server
+// never has to parse positional strings.
+
+// Status is embedded in every typed response and tells
+// whether the operation succeeded. Code is CodeOK on
+// success and the reason of the failure otherwise.
+type Status struct {
+	Ok      bool
+	Code    ErrorCode
+	Message string
+}
+
+type GetVegitableRequest struct {
+	Credentials
+	Name string
+}
+
+type ListVegitablesRequest struct {
+	Credentials
+}
+
+type GetPriceRequest struct {
+	Credentials
+	Name string
+}
+
+type GetStocksRequest struct {
+	Credentials
+	Name string
+}
+
+type AddVegitableRequest struct {
+	Credentials
+	Vegitable Vegitable
+}
+
+// UpdatePriceRequest and UpdateStocksRequest change a
+// vegitable. A non-zero Version is the version the change
+// is based on: when the vegitable is at another one by
+// now the update fails with CodeConflict.
+type UpdatePriceRequest struct {
+	Credentials
+	Name       string
+	PricePerKg Money
+	Version    uint64
+}
+
+type UpdateStocksRequest struct {
+	Credentials
+	Name         string
+	RemainingKgs Weight
+	Version      uint64
+}
+
+// DeleteVegitableRequest and RenameVegitableRequest take
+// a Version just like the updates, so a vegitable changed
+// in the meantime is neither removed nor moved unseen.
+type DeleteVegitableRequest struct {
+	Credentials
+	Name    string
+	Version uint64
+}
+
+type RenameVegitableRequest struct {
+	Credentials
+	Name    string
+	NewName string
+	Version uint64
+}
+
+type PurchaseRequest struct {
+	Credentials
+	Name string
+	Kgs  Weight
+}
+
+// PriceAtRequest asks for the unit price and stocks a
+// vegitable had at a given time.
+type PriceAtRequest struct {
+	Credentials
+	Name string
+	At   time.Time
+}
+
+type PriceHistoryRequest struct {
+	Credentials
+	Name string
+}
+
+// VegitableResponse carries a single vegitable. Mutating
+// operations return the vegitable as it is after the
+// change (or, for a delete, as it was before).
+type VegitableResponse struct {
+	Status
+	Vegitable Vegitable
+}
+
+type VegitablesResponse struct {
+	Status
+	Vegitables []Vegitable
+}
+
+type PriceResponse struct {
+	Status
+	Name       string
+	PricePerKg Money
+}
+
+type StocksResponse struct {
+	Status
+	Name         string
+	RemainingKgs Weight
+}
+
+// RevisionResponse carries the revision of a vegitable
+// that was current at the requested time.
+type RevisionResponse struct {
+	Status
+	Name     string
+	Revision Revision
+}
+
+type RevisionsResponse struct {
+	Status
+	Name      string
+	Revisions []Revision
+}
+
+// ReceiptResponse is the outcome of a purchase or an
+// order. When an order is rejected LineErrors explains
+// every line that could not be fulfilled.
+type ReceiptResponse struct {
+	Status
+	Receipt    Receipt
+	LineErrors []OrderLineError
+}
@@ -0,0 +1,43 @@
+package core
+
+import "time"
+
+// Event is a change of the inventory as seen by watchers.
+// Seq numbers the events of a server, starting at 1. Old
+// is nil for additions and New is nil for deletions, a
+// rename has both with different names.
+type Event struct {
+	Seq    uint64
+	Time   time.Time
+	Action string
+	Old    *Vegitable `json:",omitempty"`
+	New    *Vegitable `json:",omitempty"`
+}
+
+// WatchRequest asks for the events from sequence number
+// From on. When there are none yet the server waits up to
+// Wait for one before answering with no events.
+//
+// Epoch is the one of the response From was taken from:
+// sequence numbers start over whenever the server does.
+type WatchRequest struct {
+	Credentials
+	Epoch int64
+	From  uint64
+	Wait  time.Duration
+}
+
+// WatchResponse carries the events from the requested
+// sequence number on and Next, the one to ask for next.
+//
+// With Reset the events could not be told (From was 0,
+// or too old, or from before the server restarted) and
+// Vegitables holds the whole inventory up to Next instead.
+type WatchResponse struct {
+	Status
+	Epoch      int64
+	Events     []Event
+	Reset      bool
+	Vegitables []Vegitable
+	Next       uint64
+}
@@ -1,22 +1,53 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 
 	. "github.com/dimalkavindu/go-rpc/client"
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/menu"
+	"github.com/dimalkavindu/go-rpc/render"
 	. "github.com/dimalkavindu/go-rpc/server"
+	"github.com/dimalkavindu/go-rpc/store"
 )
 
 var (
-	port        = flag.Uint("port", 1337, "port to listen or connect to for rpc calls")
-	isServer    = flag.Bool("server", false, "activates server mode")
-	json        = flag.Bool("json", false, "whether it should use json-rpc")
-	serverSleep = flag.Duration("server.sleep", 0, "time for the server to sleep on requests")
-	http        = flag.Bool("http", false, "whether it should use HTTP")
+	port           = flag.Uint("port", 1337, "port to listen or connect to for rpc calls")
+	host           = flag.String("host", "127.0.0.1", "host to connect to for rpc calls")
+	isServer       = flag.Bool("server", false, "activates server mode")
+	extraPorts     = flag.String("listen.extra", "", "comma separated extra ports the server also listens on")
+	json           = flag.Bool("json", false, "whether the client should use json-rpc")
+	maxConns       = flag.Int("server.maxconns", 0, "maximum number of connections the server keeps open (0 is unlimited)")
+	idleTimeout    = flag.Duration("server.idletimeout", 0, "time after which the server closes an idle connection (0 never does)")
+	serverSleep    = flag.Duration("server.sleep", 0, "time for the server to sleep on requests")
+	http           = flag.Bool("http", false, "whether the client should use HTTP")
+	tlsCert        = flag.String("tls.cert", "", "certificate file (PEM) of the server, or of the client for mutual TLS")
+	tlsKey         = flag.String("tls.key", "", "private key file (PEM) matching -tls.cert")
+	tlsCA          = flag.String("tls.ca", "", "CA file (PEM) verifying the server, or the clients with -tls.verifyclient")
+	tlsVerify      = flag.Bool("tls.verifyclient", false, "whether the server requires client certificates signed by -tls.ca")
+	useTLS         = flag.Bool("tls", false, "whether the client should use TLS (implied by the other -tls flags)")
+	timeout        = flag.Duration("timeout", 0, "time for the client to wait for a response (0 waits forever)")
+	storeKind      = flag.String("store", "xml", "inventory backend used by the server (xml or memory)")
+	dbPath         = flag.String("db", "db.xml", "path of the file used by the xml store")
+	authTokens     = flag.String("auth.tokens", "", "file of '<token> <role> <name>' lines the server authenticates clients with (none disables auth)")
+	sessionTTL     = flag.Duration("auth.sessionttl", 0, "time a session lasts after login (0 is 12h)")
+	token          = flag.String("token", "", "API token the client logs in with")
+	auditPath      = flag.String("audit", "", "file the server records every inventory change in (none when empty)")
+	auditSize      = flag.Int64("audit.maxsize", 0, "size in bytes the audit log is rotated at (0 is 10MiB)")
+	auditKeep      = flag.Int("audit.backups", 0, "number of rotated audit logs kept (0 is 5)")
+	script         = flag.String("script", "", "file of menu commands the client runs instead of the menu")
+	scriptContinue = flag.Bool("script.continue", false, "whether the -script goes on after a command fails")
+	output         = flag.String("output", "table", "format the menu writes results in (table, json, csv or yaml)")
+	historyPath    = flag.String("history", "", "file the interactive menu keeps its command history in (default ~/.go-rpc_history, or ~/.go-rpc_server_history for the server)")
 )
 
 // handleSignals is a blocking function that waits for termination/interrupt
@@ -33,6 +64,8 @@ func handleSignals() {
 	signals := make(chan os.Signal, 1)
 
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
 	<-signals
 	log.Println("signal received")
 }
@@ -46,15 +79,103 @@ func must(err error) {
 	log.Panicln(err)
 }
 
+// historyFile returns the file given by -history or the
+// named one in the home directory, none when there is no
+// home directory.
+func historyFile(name string) string {
+	if *historyPath != "" {
+		return *historyPath
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, name)
+}
+
+// outputFormat returns the format given by -output.
+func outputFormat() render.Format {
+	format, err := render.ParseFormat(*output)
+	must(err)
+
+	return format
+}
+
+// tlsFiles returns the TLS files given by the flags and
+// whether TLS is enabled at all.
+func tlsFiles() (files core.TLSFiles, enabled bool) {
+	files = core.TLSFiles{
+		CertFile:     *tlsCert,
+		KeyFile:      *tlsKey,
+		CAFile:       *tlsCA,
+		VerifyClient: *tlsVerify,
+	}
+	enabled = *useTLS || *tlsCert != "" || *tlsKey != "" || *tlsCA != "" || *tlsVerify
+
+	return
+}
+
 // runServer sets up the server with the
 // flags as they were parsed and then initiates
 // the server listening.
 func runServer() {
+	// a store that cannot be opened is reported, not a
+	// crash.
+	st, err := store.Open(*storeKind, *dbPath)
+	if err != nil {
+		log.Println("cannot open the store:", err)
+		os.Exit(1)
+	}
+
+	var config *tls.Config
+	if files, enabled := tlsFiles(); enabled {
+		config, err = files.ServerConfig()
+		must(err)
+	}
+
+	var ports []uint
+	for _, p := range strings.Split(*extraPorts, ",") {
+		if p == "" {
+			continue
+		}
+
+		extra, err := strconv.ParseUint(p, 10, 16)
+		must(err)
+
+		ports = append(ports, uint(extra))
+	}
+
+	var auth *Auth
+	if *authTokens != "" {
+		auth, err = LoadAuth(*authTokens)
+		must(err)
+
+		auth.SessionTTL = *sessionTTL
+	}
+
+	var audit *AuditLog
+	if *auditPath != "" {
+		audit, err = OpenAuditLog(*auditPath)
+		must(err)
+
+		audit.MaxSize = *auditSize
+		audit.Backups = *auditKeep
+	}
+
 	server := &Server{
-		UseHttp: *http,
-		UseJson: *json,
-		Sleep:   *serverSleep,
-		Port:    *port,
+		MaxConns:    *maxConns,
+		IdleTimeout: *idleTimeout,
+		Sleep:       *serverSleep,
+		Port:        *port,
+		ExtraPorts:  ports,
+		Store:       st,
+		TLS:         config,
+		Auth:        auth,
+		Audit:       audit,
+		HistoryFile: historyFile(".go-rpc_server_history"),
+		Output:      outputFormat(),
 	}
 	defer server.Close()
 
@@ -73,21 +194,76 @@ func runServer() {
 // flags as they were parsed and then initiates
 // the client execution.
 func runClient() {
+	var config *tls.Config
+	if files, enabled := tlsFiles(); enabled {
+		var err error
+
+		config, err = files.ClientConfig(*host)
+		must(err)
+	}
+
 	client := &Client{
-		UseHttp: *http,
-		UseJson: *json,
-		Port:    *port,
+		Host:        *host,
+		UseHttp:     *http,
+		UseJson:     *json,
+		Port:        *port,
+		Timeout:     *timeout,
+		TLS:         config,
+		Token:       *token,
+		HistoryFile: historyFile(".go-rpc_history"),
+		Output:      outputFormat(),
 	}
 	defer client.Close()
 
-	must(client.Init())
+	// a server that cannot be reached is a failure like
+	// any other, 2 is left to usage errors.
+	if err := client.Init(); err != nil {
+		render.New(os.Stdout, client.Output).Error(err)
+		os.Exit(1)
+	}
 
+	// a signal cancels the request in flight, only when
+	// there is none the client exits.
 	go func() {
-		handleSignals()
-		client.Close()
-		os.Exit(0)
+		for {
+			handleSignals()
+			if client.Interrupt() {
+				continue
+			}
+
+			client.Close()
+			os.Exit(0)
+		}
 	}()
 
+	// a command after the flags (or a script) is run once
+	// instead of the menu, the exit status tells whether
+	// it failed.
+	cmd := flag.Args()
+	if *script != "" {
+		cmd = []string{"source", *script}
+		if *scriptContinue {
+			cmd = append(cmd, "continue")
+		}
+	}
+
+	if len(cmd) > 0 {
+		// the error is already written in the output
+		// format.
+		err := client.Run(cmd...)
+		if err == nil {
+			return
+		}
+
+		client.Close()
+
+		var usage *menu.UsageError
+		if err == menu.ErrUnknownCommand || errors.As(err, &usage) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+
 	must(client.Start())
 	return
 }
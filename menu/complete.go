@@ -0,0 +1,84 @@
+package menu
+
+import (
+	"sort"
+	"strings"
+)
+
+// Whether line runs a command with a value for one of its
+// secret arguments
+func (m *Menu) secret(line string) bool {
+	cmd, err := Split(line)
+	if err != nil || len(cmd) < 1 {
+		return false
+	}
+
+	n, args, ok := resolve(m.commands(), cmd)
+	if !ok {
+		return false
+	}
+
+	for i := range args {
+		if i < len(n.command.Args) && n.command.Args[i].Secret {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The words Tab may complete the last argument of line
+// to, sorted, along with the state line is left in. They
+// are the subcommands of the command so far and the values
+// of the argument it is at.
+func (m *Menu) complete(line string) (t tokens, candidates []string) {
+	t = tokenize(line)
+	if t.comment || t.escaped {
+		return
+	}
+
+	var names []string
+
+	words := t.args
+	helping := len(words) > 0 && words[0] == "help"
+	if helping {
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		for _, cmd := range m.commands() {
+			names = append(names, cmd.Command)
+		}
+		if !helping {
+			names = append(names, "menu", "help", "exit")
+		}
+	} else {
+		n, args, ok := resolve(m.commands(), words)
+		if !ok {
+			return
+		}
+
+		if len(args) == 0 {
+			for _, sub := range n.command.Subcommands {
+				names = append(names, sub.Command)
+			}
+		}
+
+		if !helping && n.command.Function != nil && len(args) < len(n.command.Args) {
+			if arg := n.command.Args[len(args)]; arg.Complete != nil {
+				names = append(names, arg.Complete(t.arg)...)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if strings.HasPrefix(name, t.arg) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+
+	return
+}
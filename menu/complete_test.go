@@ -0,0 +1,29 @@
+package menu
+
+import "testing"
+
+func TestSecret(t *testing.T) {
+	m := &Menu{Commands: []CommandOption{
+		{Command: "login", Function: func(args ...string) error { return nil }, Args: []Arg{{Name: "token", Secret: true}}},
+		{Command: "list", Function: func(args ...string) error { return nil }},
+	}}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"login s3cr3t", true},
+		{`login "s3cr3t" # comment`, true},
+		{"login", false},
+		{"list", false},
+		{"list s3cr3t", false},
+		{`login "unterminated`, false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := m.secret(test.line); got != test.want {
+			t.Errorf("secret(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package menu
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Keys read from the terminal besides the plain
+// characters, escape sequences are decoded into them
+const (
+	keyUnknown rune = -(iota + 1)
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// Control characters
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+// errInterrupted is returned by the editor for Ctrl-C,
+// once the terminal is back out of raw mode. The menu then
+// raises SIGINT, so that Ctrl-C does what it would without
+// the editor.
+var errInterrupted = errors.New("interrupted")
+
+// Where the menu reads its commands from, a line at a
+// time
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// Reads whole lines as they come, e.g. from a pipe
+type plainReader struct {
+	in *bufio.Reader
+}
+
+func (p plainReader) readLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		// If we didn't receive anything from ReadString
+		// we shouldn't continue because we're not blocking
+		// anymore but we also don't have any data
+		return "", err
+	}
+
+	// Drop the line ending, a backslash right before it
+	// escapes nothing
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Edits a line on a raw terminal like a shell does:
+//
+//	Left, Right, Home, End   move the cursor (so do Ctrl-B, Ctrl-F, Ctrl-A, Ctrl-E)
+//	Up, Down                 go through the history (so do Ctrl-P, Ctrl-N)
+//	Ctrl-R                   searches the history, Enter runs the match, Ctrl-G gives up
+//	Tab                      completes a command or an argument, twice lists the choices
+//	Ctrl-K, Ctrl-U, Ctrl-W   delete to the end, to the start and the word before
+//	Ctrl-D                   deletes the character under the cursor, exits on an empty line
+//	Ctrl-C                   interrupts, see errInterrupted
+//
+// A line giving a secret argument (see Arg) is not kept
+// in the history.
+type editor struct {
+	fd       uintptr
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(line string) (tokens, []string)
+	secret   func(line string) bool
+
+	prompt string
+	line   []rune
+	pos    int
+
+	// The history line shown, len(history.lines) for
+	// the line being written which is kept in pending
+	index   int
+	pending []rune
+
+	// Whether the last key was a Tab that could not
+	// complete anything by itself
+	listed bool
+}
+
+func (e *editor) readLine(prompt string) (line string, err error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return
+	}
+	defer restore()
+
+	e.prompt = prompt
+	e.line, e.pos = nil, 0
+	e.index, e.pending = len(e.history.lines), nil
+	e.refresh()
+
+	for {
+		var key rune
+
+		key, err = e.key()
+		if err != nil {
+			fmt.Fprint(e.out, "\n")
+			return
+		}
+
+		if key == ctrlR {
+			key, err = e.search()
+			if err != nil {
+				fmt.Fprint(e.out, "\n")
+				return
+			}
+		}
+
+		tabbed := key == tab
+
+		switch key {
+		case enter, '\n':
+			e.pos = len(e.line)
+			e.refresh()
+			fmt.Fprint(e.out, "\n")
+
+			line = string(e.line)
+			if e.secret == nil || !e.secret(line) {
+				e.history.add(line)
+			}
+			return
+
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupted
+
+		case ctrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			e.remove(e.pos, e.pos+1)
+
+		case keyDelete:
+			e.remove(e.pos, e.pos+1)
+
+		case backspace, ctrlH:
+			if e.pos > 0 {
+				e.remove(e.pos-1, e.pos)
+			}
+
+		case keyLeft, ctrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+
+		case keyRight, ctrlF:
+			if e.pos < len(e.line) {
+				e.pos++
+			}
+
+		case keyHome, ctrlA:
+			e.pos = 0
+
+		case keyEnd, ctrlE:
+			e.pos = len(e.line)
+
+		case keyUp, ctrlP:
+			e.browse(e.index - 1)
+
+		case keyDown, ctrlN:
+			e.browse(e.index + 1)
+
+		case ctrlK:
+			e.remove(e.pos, len(e.line))
+
+		case ctrlU:
+			e.remove(0, e.pos)
+
+		case ctrlW:
+			start := e.pos
+			for start > 0 && unicode.IsSpace(e.line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(e.line[start-1]) {
+				start--
+			}
+			e.remove(start, e.pos)
+
+		case ctrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+
+		case tab:
+			e.tab()
+
+		default:
+			if key >= ' ' {
+				e.insert(key)
+			}
+		}
+
+		e.listed = tabbed && e.listed
+		e.refresh()
+	}
+}
+
+// Read a key, decoding the escape sequences of the
+// arrows and the like
+func (e *editor) key() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != escape {
+		return r, err
+	}
+
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if r != '[' && r != 'O' {
+		return keyUnknown, nil
+	}
+
+	// parameters up to the final character of the
+	// sequence, e.g. "3~" for Delete
+	var sequence []rune
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+
+		sequence = append(sequence, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+
+	switch string(sequence) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	}
+
+	return keyUnknown, nil
+}
+
+// Draw the prompt and the line over the current one and
+// put the cursor back where it is in the line
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *editor) insert(runes ...rune) {
+	line := append([]rune(nil), e.line[:e.pos]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.pos:]...)
+	e.pos += len(runes)
+}
+
+func (e *editor) remove(from, to int) {
+	if to > len(e.line) {
+		to = len(e.line)
+	}
+	if from >= to {
+		return
+	}
+
+	e.line = append(e.line[:from:from], e.line[to:]...)
+	e.pos = from
+}
+
+// Show the history line at index, the line being written
+// past the last one
+func (e *editor) browse(index int) {
+	lines := e.history.lines
+	if index < 0 || index > len(lines) || index == e.index {
+		return
+	}
+
+	if e.index == len(lines) {
+		e.pending = e.line
+	}
+
+	e.index = index
+	if index == len(lines) {
+		e.line = e.pending
+	} else {
+		e.line = []rune(lines[index])
+	}
+	e.pos = len(e.line)
+}
+
+// Search the history backwards as the query is typed,
+// Ctrl-R goes on to an older match. Any other key takes
+// the match as the line and is returned to be handled as
+// usual, Ctrl-G gives up leaving the line as it was and so
+// does Ctrl-C, which is returned to interrupt.
+func (e *editor) search() (key rune, err error) {
+	var (
+		query []rune
+		found = len(e.history.lines)
+	)
+
+	line, pos := e.line, e.pos
+
+	for {
+		failed := ""
+		match := ""
+		if found >= 0 && found < len(e.history.lines) {
+			match = e.history.lines[found]
+		}
+		if found < 0 {
+			failed = "failed "
+		}
+		fmt.Fprintf(e.out, "\r(%sreverse-i-search)`%s': %s\x1b[K", failed, string(query), match)
+
+		key, err = e.key()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case key == ctrlR:
+			if i := e.history.search(string(query), found); i >= 0 {
+				found = i
+			}
+
+		case key == backspace || key == ctrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				found = e.history.search(string(query), len(e.history.lines))
+			}
+
+		case key == ctrlG || key == ctrlC:
+			e.line, e.pos = line, pos
+			if key == ctrlC {
+				return key, nil
+			}
+			return 0, nil
+
+		case key >= ' ':
+			query = append(query, key)
+			if found < 0 || found >= len(e.history.lines) || !strings.Contains(e.history.lines[found], string(query)) {
+				from := found + 1
+				if found < 0 {
+					from = len(e.history.lines)
+				}
+				found = e.history.search(string(query), from)
+			}
+
+		default:
+			if match != "" {
+				if e.index == len(e.history.lines) {
+					e.pending = line
+				}
+
+				e.index = found
+				e.line = []rune(match)
+				e.pos = len(e.line)
+			}
+			return
+		}
+	}
+}
+
+// Complete the argument before the cursor as far as all
+// the choices agree, a second Tab lists them
+func (e *editor) tab() {
+	before := string(e.line[:e.pos])
+
+	t, candidates := e.complete(before)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	common := []rune(candidates[0])
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, string(common)) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(candidates) == 1 || len(string(common)) > len(t.arg) {
+		word := quote(string(common), t.quote, len(candidates) == 1)
+
+		rest := e.line[e.pos:]
+		e.line = append([]rune(before[:t.start]+word), rest...)
+		e.pos = len(e.line) - len(rest)
+		return
+	}
+
+	if !e.listed {
+		e.listed = true
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+}
+
+// Write a value as an argument, within the quote the
+// user opened if any. A complete one is closed and
+// followed by a space.
+func quote(value string, q rune, complete bool) (word string) {
+	word = escapeValue(value, q)
+	if q != 0 {
+		word = string(q) + word
+	}
+
+	if complete {
+		if q != 0 {
+			word += string(q)
+		}
+		word += " "
+	}
+
+	return
+}
+
+// A line editor for the terminal on stdin, nil when there
+// is none to edit lines on
+func (m *Menu) editor() *editor {
+	if !isTerminal(os.Stdin.Fd()) || !isTerminal(os.Stdout.Fd()) {
+		return nil
+	}
+
+	return &editor{
+		fd:       os.Stdin.Fd(),
+		in:       bufio.NewReader(os.Stdin),
+		out:      os.Stdout,
+		history:  loadHistory(m.Options.HistoryFile, m.Options.HistorySize),
+		complete: m.complete,
+		secret:   m.secret,
+	}
+}
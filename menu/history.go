@@ -0,0 +1,112 @@
+package menu
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// DefaultHistorySize is the number of lines kept in the
+// history when MenuOptions.HistorySize is not set.
+const DefaultHistorySize = 1000
+
+// The lines entered at the prompt, oldest first, kept in
+// a file (one line each) when there is one
+type history struct {
+	path  string
+	size  int
+	lines []string
+}
+
+// Load the history kept in path, a missing file is an
+// empty history. A file grown past size is cut back to
+// its last size lines.
+func loadHistory(path string, size int) *history {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	h := &history{path: path, size: size}
+	if path == "" {
+		return h
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+
+	for scanner.Scan() {
+		h.lines = append(h.lines, scanner.Text())
+	}
+
+	if len(h.lines) > size {
+		h.lines = h.lines[len(h.lines)-size:]
+		h.save()
+	}
+
+	return h
+}
+
+// Add a line unless it is blank or the same as the last
+// one, the file is only ever appended to
+func (h *history) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.size {
+		h.lines = h.lines[len(h.lines)-h.size:]
+	}
+
+	if h.path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	file.WriteString(line + "\n")
+}
+
+// Write the whole history over the file
+func (h *history) save() {
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, line := range h.lines {
+		w.WriteString(line + "\n")
+	}
+	w.Flush()
+}
+
+// The index of the latest line before from containing
+// query, -1 when there is none
+func (h *history) search(query string, from int) int {
+	if from > len(h.lines) {
+		from = len(h.lines)
+	}
+
+	for i := from - 1; i >= 0; i-- {
+		if strings.Contains(h.lines[i], query) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -14,18 +14,20 @@ func layoutMenu(w *tabwriter.Writer, cmds []CommandOption, width int) {
 		fmt.Fprintf(w, "*\t%s\t", cmds[i].Command)
 
 		// Check description length
-		description_length := len(cmds[i].Description)
+		description := overview(cmds[i])
+		description_length := len(description)
 
 		if description_length <= width {
-			fmt.Fprintf(w, "%s\t\n", cmds[i].Description)
+			fmt.Fprintf(w, "%s\t\n", description)
 			continue
 		}
 
 		if description_length > width {
-			layoutLongDescription(w, cmds[i].Description, width)
+			layoutLongDescription(w, description, width)
 		}
 
 	}
+	fmt.Fprintln(w, "*\thelp\t: 'help <command> [subcommand]' explains a command\t")
 	fmt.Fprintln(w, "*\texit\t: Exiting from the application\t")
 	fmt.Fprintln(w)
 	w.Flush()
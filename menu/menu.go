@@ -2,30 +2,59 @@ package menu
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strings"
 	"text/tabwriter"
+
+	"github.com/dimalkavindu/go-rpc/render"
 )
 
+// ErrUnknownCommand is returned by Run for a command that
+// is not in the menu.
+var ErrUnknownCommand = errors.New("Unknown command")
+
 // Main struct to handle options for Command, Description, and the
 // function that should be called
+//
+// Args declares the arguments Function is called with, the
+// menu rejects anything else with a *UsageError before
+// calling it. Subcommands are commands nested under this
+// one, e.g. "price" and "stocks" under "update". A command
+// with subcommands only needs a Function if it can be run
+// on its own as well.
+//
+// The menu and the help are generated from Command,
+// Description (a short sentence) and Args.
 type CommandOption struct {
 	Command, Description string
 	Function             func(args ...string) error
+	Args                 []Arg
+	Subcommands          []CommandOption
 }
 
-// Menu options -- right now only sets prompt
+// Menu options -- the prompt, the width of the menu,
+// where the history of the line editor is kept (nowhere
+// when HistoryFile is empty) and the renderer failures are
+// reported with (tables on stdout when nil). `set output`
+// changes the format of the renderer.
 type MenuOptions struct {
-	Prompt     string
-	MenuLength int
+	Prompt      string
+	MenuLength  int
+	HistoryFile string
+	HistorySize int
+	Renderer    *render.Renderer
 }
 
-// Menu struct encapsulates Commands and Options
+// Menu struct encapsulates Commands and Options, along
+// with the variables set in it and the depth of the
+// scripts running
 type Menu struct {
 	Commands []CommandOption
 	Options  MenuOptions
+
+	variables map[string]string
+	depth     int
 }
 
 // Setup the options for the menu.
@@ -41,87 +70,133 @@ func NewMenuOptions(prompt string, length int) MenuOptions {
 		length = 100
 	}
 
-	return MenuOptions{prompt, length}
-}
-
-// Trim whitespace, newlines, and create command+arguments slice
-func cleanCommand(cmd string) ([]string, error) {
-	cmd_args := strings.Split(strings.Trim(cmd, " \n"), " ")
-	return cmd_args, nil
+	return MenuOptions{Prompt: prompt, MenuLength: length}
 }
 
 // Creates a new menu with options
 func NewMenu(cmds []CommandOption, options MenuOptions) *Menu {
-	return &Menu{cmds, options}
-}
-
-func (m *Menu) prompt() {
-	fmt.Print(m.Options.Prompt)
+	return &Menu{Commands: cmds, Options: options}
 }
 
 // Write menu from CommandOptions with tabwriter
 func (m *Menu) menu() {
 	w := new(tabwriter.Writer)
 	w.Init(os.Stdout, 5, 0, 1, ' ', 0)
-	layoutMenu(w, m.Commands, m.Options.MenuLength)
+	layoutMenu(w, m.commands(), m.Options.MenuLength)
 }
 
-// Wrapper for providing Stdin to the main menu loop
+// The renderer of the results
+func (m *Menu) output() *render.Renderer {
+	if m.Options.Renderer == nil {
+		m.Options.Renderer = render.New(os.Stdout, render.FormatTable)
+	}
+
+	return m.Options.Renderer
+}
+
+// The commands of the menu followed by the builtins
+func (m *Menu) commands() []CommandOption {
+	return append(m.Commands[:len(m.Commands):len(m.Commands)], m.builtins()...)
+}
+
+// Wrapper for providing Stdin to the main menu loop. On a
+// terminal lines are edited with history and completion
+// (see editor), otherwise they are read as they come.
 func (m *Menu) Start() {
-	m.start(os.Stdin)
+	if e := m.editor(); e != nil {
+		m.start(e)
+		return
+	}
+
+	m.start(plainReader{bufio.NewReader(os.Stdin)})
 }
 
 // Main loop
-func (m *Menu) start(reader io.Reader) {
+func (m *Menu) start(lines lineReader) {
 	m.menu()
 MainLoop:
 	for {
-		input := bufio.NewReader(reader)
-		// Prompt for input
-		m.prompt()
-
-		inputString, err := input.ReadString('\n')
+		inputString, err := lines.readLine(m.Options.Prompt)
+		if err == errInterrupted {
+			// the menu stops reading, whoever handles the
+			// signal may well exit
+			interrupt()
+			break MainLoop
+		}
 		if err != nil {
-			// If we didn't receive anything from ReadString
-			// we shouldn't continue because we're not blocking
-			// anymore but we also don't have any data
 			break MainLoop
 		}
 
-		// convert CRLF to LF
-		inputString = strings.Replace(inputString, "\r\n", "", -1)
+		// variables are only replaced in scripts, a '$'
+		// typed at the prompt is kept as it is
+		cmd, err := Split(inputString)
+		if err != nil {
+			m.output().Error(err)
+			continue
+		}
 
-		cmd, _ := cleanCommand(inputString)
+		// Nothing to do for a blank line or a comment
 		if len(cmd) < 1 {
-			break MainLoop
+			continue
 		}
 		// Route the first index of the cmd slice to the appropriate case
-	Route:
 		switch cmd[0] {
 		case "exit", "quit":
 			fmt.Println("Exiting...")
 			break MainLoop
 
-		case "menu":
-			m.menu()
-			break
-
 		default:
-			// Loop through commands and find the right one
-			// Probably a more efficient way to do this, but unless we have
-			// tons of commands, it probably doesn't matter
-			for i := range m.Commands {
-				if m.Commands[i].Command == cmd[0] {
-					err := m.Commands[i].Function(cmd[1:]...)
-					if err != nil {
-						panic(err)
-					}
-
-					break Route
-				}
+			// A failed command is reported and the menu
+			// goes on with the next one
+			if err := m.Run(cmd...); err != nil {
+				m.output().Error(err)
 			}
-			// Shouldn't get here if we found a command
-			fmt.Println("Unknown command")
 		}
 	}
 }
+
+// Run executes a single command, e.g. as given on the
+// command line, and returns the error of its function.
+//
+// The words naming subcommands are followed as far as
+// they go, the remaining ones are the arguments.
+func (m *Menu) Run(cmd ...string) error {
+	if len(cmd) < 1 {
+		return ErrUnknownCommand
+	}
+
+	switch cmd[0] {
+	case "menu":
+		m.menu()
+		return nil
+	case "help":
+		return m.help(cmd[1:])
+	}
+
+	n, args, ok := resolve(m.commands(), cmd)
+	if !ok {
+		return ErrUnknownCommand
+	}
+
+	if err := n.check(args); err != nil {
+		return err
+	}
+
+	return n.command.Function(args...)
+}
+
+// Print the menu, or the help of the given command
+func (m *Menu) help(cmd []string) error {
+	if len(cmd) < 1 {
+		m.menu()
+		return nil
+	}
+
+	n, args, ok := resolve(m.commands(), cmd)
+	if !ok || len(args) > 0 {
+		return ErrUnknownCommand
+	}
+
+	help(os.Stdout, n)
+	return nil
+}
@@ -0,0 +1,244 @@
+package menu
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrUnterminatedQuote is returned by Split for a line
+	// with a quote that is never closed.
+	ErrUnterminatedQuote = errors.New("Unterminated quote")
+
+	// ErrTrailingBackslash is returned by Split for a line
+	// ending with a backslash that escapes nothing.
+	ErrTrailingBackslash = errors.New("Nothing to escape after '\\'")
+)
+
+// Split breaks a command line into its arguments the way
+// a shell does:
+//
+//	add vegitable "sweet potato" 120 5   quotes group words
+//	add vegitable sweet\ potato 120 5    so does a backslash
+//	show   price  Beans                  spaces are collapsed
+//	show price Beans  # a comment        '#' starts a comment
+//
+// Single quotes keep everything as it is, within double
+// quotes a backslash only escapes '"', '\' and '$'. A
+// blank line (or a comment) has no arguments at all.
+func Split(line string) (args []string, err error) {
+	t := tokenize(line)
+
+	if t.escaped {
+		return nil, ErrTrailingBackslash
+	}
+	if t.quote != 0 {
+		return nil, ErrUnterminatedQuote
+	}
+
+	args = t.args
+	if t.inArg {
+		args = append(args, t.arg)
+	}
+
+	return
+}
+
+// The state Split leaves a line in, for completing the
+// argument it ends with
+type tokens struct {
+	// The arguments before the last one
+	args []string
+
+	// The last argument so far, unquoted, and the byte
+	// offset it starts at. inArg is false when the line
+	// ends between arguments.
+	arg   string
+	start int
+	inArg bool
+
+	// The quote left open and whether a backslash is
+	// waiting for the character it escapes
+	quote   rune
+	escaped bool
+
+	// Whether the line ends in a comment
+	comment bool
+}
+
+func tokenize(line string) (t tokens) {
+	var arg strings.Builder
+
+	for i, r := range line {
+		switch {
+		case t.escaped:
+			if t.quote == '"' && r != '"' && r != '\\' && r != '$' {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			t.escaped = false
+
+		case t.quote == '\'':
+			if r == '\'' {
+				t.quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+
+		case t.quote == '"':
+			switch r {
+			case '"':
+				t.quote = 0
+			case '\\':
+				t.escaped = true
+			default:
+				arg.WriteRune(r)
+			}
+
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			if t.inArg {
+				t.args = append(t.args, arg.String())
+				arg.Reset()
+				t.inArg = false
+			}
+
+		case r == '#' && !t.inArg:
+			t.comment = true
+			return
+
+		default:
+			if !t.inArg {
+				t.start = i
+				t.inArg = true
+			}
+
+			switch r {
+			case '\\':
+				t.escaped = true
+			case '\'', '"':
+				t.quote = r
+			default:
+				arg.WriteRune(r)
+			}
+		}
+	}
+
+	t.arg = arg.String()
+	if !t.inArg {
+		t.start = len(line)
+	}
+
+	return
+}
+
+// Escape a value so that Split reads it back as it is,
+// within the given quote (0 for none). Single quotes can
+// not hold a "'".
+func escapeValue(value string, quote rune) string {
+	special := " \t'\"\\#$"
+	switch quote {
+	case '\'':
+		return value
+	case '"':
+		special = "\"\\$"
+	}
+
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// ErrUnterminatedVariable is returned by expand for a
+// '${' that is never closed.
+var ErrUnterminatedVariable = errors.New("Unterminated '${'")
+
+// Replace $name and ${name} in a line with the values of
+// the variables, before it is split. A value always ends
+// up as it is in a single argument. Nothing is replaced
+// within single quotes, after a backslash or in a comment.
+func expand(line string, variables map[string]string) (string, error) {
+	var (
+		b       strings.Builder
+		runes   = []rune(line)
+		quote   rune
+		escaped bool
+	)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case escaped:
+			escaped = false
+
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			}
+
+		case r == '\\':
+			escaped = true
+
+		case r == '"' && quote == '"':
+			quote = 0
+
+		case (r == '\'' || r == '"') && quote == 0:
+			quote = r
+
+		case r == '#' && quote == 0 && (i == 0 || strings.ContainsRune(" \t", runes[i-1])):
+			b.WriteString(string(runes[i:]))
+			return b.String(), nil
+
+		case r == '$':
+			name, end := "", i+1
+			if end < len(runes) && runes[end] == '{' {
+				closing := end
+				for closing < len(runes) && runes[closing] != '}' {
+					closing++
+				}
+				if closing == len(runes) {
+					return "", ErrUnterminatedVariable
+				}
+
+				name, end = string(runes[end+1:closing]), closing+1
+			} else {
+				for end < len(runes) && isNameRune(runes[end], end == i+1) {
+					end++
+				}
+
+				name = string(runes[i+1 : end])
+			}
+
+			// a '$' naming nothing is kept as it is
+			if name == "" && end == i+1 {
+				break
+			}
+
+			value, ok := variables[name]
+			if !ok {
+				return "", errors.New("Unknown variable '$" + name + "'")
+			}
+
+			b.WriteString(escapeValue(value, quote))
+			i = end - 1
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String(), nil
+}
+
+// Whether r may be in the name of a variable, a name
+// starts with a letter or '_'
+func isNameRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
@@ -0,0 +1,146 @@
+package menu
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"# a comment", nil},
+		{"show price Beans", []string{"show", "price", "Beans"}},
+		{"  show   price\tBeans  ", []string{"show", "price", "Beans"}},
+		{`add vegitable "sweet potato" 120 5`, []string{"add", "vegitable", "sweet potato", "120", "5"}},
+		{`add vegitable 'sweet potato' 120 5`, []string{"add", "vegitable", "sweet potato", "120", "5"}},
+		{`add vegitable sweet\ potato 120 5`, []string{"add", "vegitable", "sweet potato", "120", "5"}},
+		{`show price Beans # the cheap ones`, []string{"show", "price", "Beans"}},
+		{`show price Bean#s`, []string{"show", "price", "Bean#s"}},
+		{`show price "#1"`, []string{"show", "price", "#1"}},
+		{`a"b c"d`, []string{"ab cd"}},
+		{`""`, []string{""}},
+		{`'it''s'`, []string{"its"}},
+		{`"say \"hi\""`, []string{`say "hi"`}},
+		{`"a\b \\ \$"`, []string{`a\b \ $`}},
+		{`'a\b "c"'`, []string{`a\b "c"`}},
+		{`\$5 \'`, []string{"$5", "'"}},
+	}
+
+	for _, test := range tests {
+		got, err := Split(test.line)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Split(%q) = %q, %v, want %q", test.line, got, err, test.want)
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want error
+	}{
+		{`show price "Beans`, ErrUnterminatedQuote},
+		{`show price 'Beans`, ErrUnterminatedQuote},
+		{`show price Beans\`, ErrTrailingBackslash},
+		{`"Beans\`, ErrTrailingBackslash},
+	}
+
+	for _, test := range tests {
+		if _, err := Split(test.line); err != test.want {
+			t.Errorf("Split(%q) = %v, want %v", test.line, err, test.want)
+		}
+	}
+}
+
+// An escaped value reads back as it is, whatever quote it
+// is written in.
+func TestEscapeValue(t *testing.T) {
+	values := []string{"Beans", "sweet potato", `it's "hot"`, `a\b`, "#1", "$5 a kg", "tab\there"}
+
+	for _, value := range values {
+		for _, quote := range []rune{0, '"', '\''} {
+			if quote == '\'' && strings.ContainsRune(value, '\'') {
+				continue
+			}
+
+			line := escapeValue(value, quote)
+			if quote != 0 {
+				line = string(quote) + line + string(quote)
+			}
+
+			got, err := Split(line)
+			if err != nil || len(got) != 1 || got[0] != value {
+				t.Errorf("%q escaped within %q as %s splits into %q, %v", value, quote, line, got, err)
+			}
+		}
+	}
+}
+
+// The tokens of an unfinished line tell what to complete.
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line  string
+		args  []string
+		arg   string
+		start int
+		quote rune
+	}{
+		{"show pri", []string{"show"}, "pri", 5, 0},
+		{"show price ", []string{"show", "price"}, "", 11, 0},
+		{`show price "sweet po`, []string{"show", "price"}, "sweet po", 11, '"'},
+		{`show price sweet\ po`, []string{"show", "price"}, "sweet po", 11, 0},
+	}
+
+	for _, test := range tests {
+		got := tokenize(test.line)
+		if !reflect.DeepEqual(got.args, test.args) || got.arg != test.arg || got.start != test.start || got.quote != test.quote {
+			t.Errorf("tokenize(%q) = %+v, want args %q, arg %q at %d in quote %q", test.line, got, test.args, test.arg, test.start, test.quote)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	variables := map[string]string{"beans": "180", "name": "Sweet Potato", "quote": `it's "hot"`}
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"update price Beans $beans", []string{"update", "price", "Beans", "180"}},
+		{"update price $name ${beans}0", []string{"update", "price", "Sweet Potato", "1800"}},
+		{`add "$name" 1 2`, []string{"add", "Sweet Potato", "1", "2"}},
+		{"add $quote", []string{"add", `it's "hot"`}},
+		{`add "$quote"`, []string{"add", `it's "hot"`}},
+		{"add '$beans'", []string{"add", "$beans"}},
+		{`add \$beans`, []string{"add", "$beans"}},
+		{"add $ 5", []string{"add", "$", "5"}},
+		{"add 5 # $missing", []string{"add", "5"}},
+	}
+
+	for _, test := range tests {
+		line, err := expand(test.line, variables)
+		if err != nil {
+			t.Errorf("expand(%q) = %v", test.line, err)
+			continue
+		}
+
+		got, err := Split(line)
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expand(%q) = %s, splits into %q, %v, want %q", test.line, line, got, err, test.want)
+		}
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	if _, err := expand("add $missing", nil); err == nil || !strings.Contains(err.Error(), "$missing") {
+		t.Errorf("got %v, want an unknown variable", err)
+	}
+	if _, err := expand("add ${beans", map[string]string{"beans": "180"}); err != ErrUnterminatedVariable {
+		t.Errorf("got %v, want ErrUnterminatedVariable", err)
+	}
+}
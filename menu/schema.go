@@ -0,0 +1,235 @@
+package menu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Arg declares an argument of a command. Optional
+// arguments can only come after the required ones.
+//
+// Complete, when set, returns the values Tab may complete
+// the argument to, e.g. the names of the vegitables. The
+// ones not starting with prefix are left out anyway.
+//
+// A Secret argument, e.g. a password or a token, keeps
+// the lines giving it out of the history.
+type Arg struct {
+	Name     string
+	Type     Type
+	Optional bool
+	Complete func(prefix string) []string
+	Secret   bool
+}
+
+// Type tells what an argument accepts. Check, when set,
+// returns an error for a value that is not acceptable.
+// The zero Type accepts any text.
+type Type struct {
+	Name  string
+	Check func(value string) error
+}
+
+var decimal = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)$`)
+
+var (
+	// Text accepts anything
+	Text = Type{Name: "text"}
+
+	// Number accepts whole numbers from 0 on
+	Number = Type{Name: "whole number", Check: func(value string) error {
+		_, err := strconv.ParseUint(value, 10, 64)
+		return err
+	}}
+
+	// Decimal accepts numbers with an optional fraction,
+	// e.g. 12, 12.5 or .5
+	Decimal = Type{Name: "decimal number", Check: func(value string) error {
+		if !decimal.MatchString(value) {
+			return errors.New("not a decimal number")
+		}
+		return nil
+	}}
+)
+
+// UsageError is returned by Run when a command is given
+// wrong arguments. Usage lists the right ways to call it.
+type UsageError struct {
+	Message string
+	Usage   []string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message + "\nUsage: " + strings.Join(e.Usage, "\n       ")
+}
+
+// A command along with the words leading to it
+type node struct {
+	path    []string
+	command CommandOption
+}
+
+func (n node) name() string {
+	return strings.Join(n.path, " ")
+}
+
+// The way to call a command, e.g. "update price <name> <price> [version]"
+func (n node) usage() string {
+	usage := n.name()
+	for _, arg := range n.command.Args {
+		if arg.Optional {
+			usage += " [" + arg.Name + "]"
+		} else {
+			usage += " <" + arg.Name + ">"
+		}
+	}
+
+	return usage
+}
+
+// Every command under n (n included) that can be run,
+// depth first
+func (n node) runnable() (nodes []node) {
+	if n.command.Function != nil {
+		nodes = append(nodes, n)
+	}
+
+	for _, sub := range n.command.Subcommands {
+		child := node{path: append(append([]string(nil), n.path...), sub.Command), command: sub}
+		nodes = append(nodes, child.runnable()...)
+	}
+
+	return
+}
+
+func (n node) usages() (usages []string) {
+	for _, r := range n.runnable() {
+		usages = append(usages, r.usage())
+	}
+
+	return
+}
+
+// Find the command with the given name
+func find(cmds []CommandOption, name string) (CommandOption, bool) {
+	for i := range cmds {
+		if cmds[i].Command == name {
+			return cmds[i], true
+		}
+	}
+
+	return CommandOption{}, false
+}
+
+// Follow the subcommands as far as the words name them,
+// returns the command reached and the remaining words
+func resolve(cmds []CommandOption, words []string) (n node, args []string, ok bool) {
+	if len(words) < 1 {
+		return
+	}
+
+	n.command, ok = find(cmds, words[0])
+	if !ok {
+		return
+	}
+	n.path = words[:1]
+	args = words[1:]
+
+	for len(args) > 0 {
+		sub, found := find(n.command.Subcommands, args[0])
+		if !found {
+			break
+		}
+
+		n.command = sub
+		n.path = words[:len(n.path)+1]
+		args = args[1:]
+	}
+
+	return
+}
+
+// Check the arguments against the ones the command
+// declares
+func (n node) check(args []string) error {
+	if n.command.Function == nil {
+		return &UsageError{
+			Message: "Unknown command format: '" + strings.Join(append(append([]string(nil), n.path...), args...), " ") + "'",
+			Usage:   n.usages(),
+		}
+	}
+
+	required := 0
+	for _, arg := range n.command.Args {
+		if !arg.Optional {
+			required++
+		}
+	}
+
+	if len(args) < required || len(args) > len(n.command.Args) {
+		return &UsageError{
+			Message: "Invalid number of inputs for '" + n.name() + "' command!",
+			Usage:   []string{n.usage()},
+		}
+	}
+
+	for i, value := range args {
+		arg := n.command.Args[i]
+		if arg.Type.Check == nil {
+			continue
+		}
+
+		if arg.Type.Check(value) != nil {
+			return &UsageError{
+				Message: "Invalid " + arg.Name + " '" + value + "'! It should be a " + arg.Type.Name + ".",
+				Usage:   []string{n.usage()},
+			}
+		}
+	}
+
+	return nil
+}
+
+// Write the help of a command and its subcommands
+func help(w io.Writer, n node) {
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 5, 0, 2, ' ', 0)
+
+	for _, r := range n.runnable() {
+		fmt.Fprintf(tw, "%s\n", r.usage())
+		if r.command.Description != "" {
+			fmt.Fprintf(tw, "    %s\n", r.command.Description)
+		}
+
+		for _, arg := range r.command.Args {
+			typ := arg.Type.Name
+			if typ == "" {
+				typ = Text.Name
+			}
+			if arg.Optional {
+				typ += ", optional"
+			}
+
+			fmt.Fprintf(tw, "    \t%s\t%s\t\n", arg.Name, typ)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	tw.Flush()
+}
+
+// The lines describing a command in the menu, one per
+// way of calling it
+func overview(cmd CommandOption) string {
+	var lines []string
+	for _, r := range (node{path: []string{cmd.Command}, command: cmd}).runnable() {
+		lines = append(lines, "\t"+r.usage()+"\t: "+r.command.Description)
+	}
+
+	return "\n" + strings.Join(lines, "\n")
+}
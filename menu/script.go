@@ -0,0 +1,257 @@
+package menu
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/dimalkavindu/go-rpc/render"
+)
+
+// maxScriptDepth bounds scripts sourcing other scripts,
+// e.g. one sourcing itself.
+const maxScriptDepth = 16
+
+// ScriptError is returned by Source when some commands of
+// a script failed (or were not run after one did).
+type ScriptError struct {
+	Path                       string
+	Succeeded, Failed, Skipped int
+}
+
+func (e *ScriptError) Error() string {
+	return summary(e.Path, e.Succeeded, e.Failed, e.Skipped)
+}
+
+func summary(path string, succeeded, failed, skipped int) string {
+	s := path + ": " + strconv.Itoa(succeeded) + " succeeded, " + strconv.Itoa(failed) + " failed"
+	if skipped > 0 {
+		s += ", " + strconv.Itoa(skipped) + " not run"
+	}
+
+	return s
+}
+
+// Source runs the commands of a script, a line each, as if
+// they were entered at the prompt, except that variables
+// are replaced (see expand). Blank lines and comments are
+// skipped and `exit` ends the script.
+//
+// The first failure stops the script unless keepGoing.
+// Each command is echoed before it runs and a summary of
+// the successes and failures is written at the end, as the
+// error when there is any failure.
+func (m *Menu) Source(path string, keepGoing bool) error {
+	if m.depth >= maxScriptDepth {
+		return errors.New("Scripts are nested too deep!")
+	}
+
+	m.depth++
+	defer func() { m.depth-- }()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		scanner                    = bufio.NewScanner(file)
+		succeeded, failed, skipped int
+		number                     int
+	)
+
+	for scanner.Scan() {
+		number++
+		line := scanner.Text()
+
+		cmd, err := m.parse(line)
+		if err == nil && len(cmd) < 1 {
+			continue
+		}
+
+		if failed > 0 && !keepGoing {
+			skipped++
+			continue
+		}
+
+		// the echo is not part of the results, which other
+		// programs may be reading
+		echo := os.Stdout
+		if m.output().Structured() {
+			echo = os.Stderr
+		}
+		fmt.Fprintf(echo, "%s:%d> %s\n", path, number, line)
+
+		if err == nil && (cmd[0] == "exit" || cmd[0] == "quit") {
+			break
+		}
+		if err == nil {
+			err = m.Run(cmd...)
+		}
+
+		if err != nil {
+			m.output().Error(located(path, number, err))
+			failed++
+			continue
+		}
+
+		succeeded++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return &ScriptError{Path: path, Succeeded: succeeded, Failed: failed, Skipped: skipped}
+	}
+
+	m.output().Message(summary(path, succeeded, failed, skipped))
+	return nil
+}
+
+// located tells the error of a line of a script where
+// it comes from, as a Location when it is a render.Error
+// so that the structured formats keep it too.
+func located(path string, number int, err error) error {
+	location := path + ":" + strconv.Itoa(number)
+
+	var e *render.Error
+	if !errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", location, err)
+	}
+
+	l := *e
+	l.Location = location
+	if e.Location != "" {
+		l.Location += ": " + e.Location
+	}
+
+	return &l
+}
+
+// Split a line into a command after replacing the
+// variables in it
+func (m *Menu) parse(line string) ([]string, error) {
+	line, err := expand(line, m.variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return Split(line)
+}
+
+// The commands every menu has besides its own
+func (m *Menu) builtins() []CommandOption {
+	variable := Arg{Name: "name", Type: Type{Name: "name of letters, digits and '_'", Check: checkName}, Complete: m.variableNames}
+
+	return []CommandOption{
+		{Command: "source", Description: "Runs the commands of a file, going on after a failure only when asked to", Args: []Arg{
+			{Name: "file"},
+			{Name: "on failure", Type: Type{Name: "'stop' or 'continue'", Check: checkOnFailure}, Optional: true, Complete: func(string) []string {
+				return []string{"stop", "continue"}
+			}},
+		}, Function: func(args ...string) error {
+			return m.Source(args[0], len(args) > 1 && args[1] == "continue")
+		}},
+		{Command: "set", Description: "Sets a variable that $name or ${name} is replaced with in scripts, or the output format (table, json, csv or yaml) as 'output', shows them without a value", Args: []Arg{
+			{Name: variable.Name, Type: variable.Type, Optional: true, Complete: variable.Complete},
+			{Name: "value", Optional: true},
+		}, Function: m.set},
+		{Command: "unset", Description: "Removes a variable", Args: []Arg{variable}, Function: m.unset},
+	}
+}
+
+func (m *Menu) set(args ...string) error {
+	if len(args) < 2 {
+		return m.showVariables(args...)
+	}
+
+	if args[0] == "output" {
+		return m.output().SetFormat(args[1])
+	}
+
+	if m.variables == nil {
+		m.variables = make(map[string]string)
+	}
+
+	m.variables[args[0]] = args[1]
+	return nil
+}
+
+func (m *Menu) unset(args ...string) error {
+	if _, ok := m.variables[args[0]]; !ok {
+		return errors.New("Unknown variable '$" + args[0] + "'")
+	}
+
+	delete(m.variables, args[0])
+	return nil
+}
+
+// Show the variable named, or every one along with the
+// output format
+func (m *Menu) showVariables(names ...string) error {
+	var record render.Record
+
+	if len(names) == 0 {
+		record = append(record, m.outputField())
+		names = m.variableNames("")
+	}
+
+	for _, name := range names {
+		if name == "output" {
+			record = append(record, m.outputField())
+			continue
+		}
+
+		value, ok := m.variables[name]
+		if !ok {
+			return errors.New("Unknown variable '$" + name + "'")
+		}
+
+		record = append(record, render.Field{Column: render.Column{Title: name, Key: name}, Value: value})
+	}
+
+	m.output().Record(record)
+	return nil
+}
+
+// The output format as a field
+func (m *Menu) outputField() render.Field {
+	return render.Field{Column: render.Column{Title: "output", Key: "output"}, Value: string(m.output().Format)}
+}
+
+func (m *Menu) variableNames(prefix string) (names []string) {
+	for name := range m.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
+
+func checkName(value string) error {
+	for i, r := range value {
+		if !isNameRune(r, i == 0) {
+			return errors.New("not a name")
+		}
+	}
+
+	if value == "" {
+		return errors.New("not a name")
+	}
+
+	return nil
+}
+
+func checkOnFailure(value string) error {
+	if value != "stop" && value != "continue" {
+		return errors.New("neither stop nor continue")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package menu
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimalkavindu/go-rpc/render"
+)
+
+// The line of a script a failure comes from is kept in
+// the structured output too.
+func TestSourceLocatesFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.txt")
+	if err := ioutil.WriteFile(path, []byte("# prices\nok\nfail\nplain\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	m := &Menu{
+		Commands: []CommandOption{
+			{Command: "ok", Function: func(args ...string) error { return nil }},
+			{Command: "fail", Function: func(args ...string) error {
+				return &render.Error{Code: "NotFound", Message: "Vegitable 'Beans' is not found!"}
+			}},
+			{Command: "plain", Function: func(args ...string) error { return ErrUnknownCommand }},
+		},
+		Options: MenuOptions{Renderer: render.New(&out, render.FormatJSON)},
+	}
+
+	if err := m.Source(path, true); err == nil {
+		t.Fatal("the script succeeded")
+	}
+
+	decoder := json.NewDecoder(&out)
+
+	var failure struct{ Code, Message, Location string }
+	if err := decoder.Decode(&failure); err != nil {
+		t.Fatal(err)
+	}
+	if failure.Code != "NotFound" || failure.Location != path+":3" || strings.Contains(failure.Message, path) {
+		t.Errorf("got %+v, want the failure of line 3", failure)
+	}
+
+	if err := decoder.Decode(&failure); err != nil {
+		t.Fatal(err)
+	}
+	if failure.Message != path+":4: "+ErrUnknownCommand.Error() {
+		t.Errorf("got %+v, want the failure of line 4", failure)
+	}
+
+	want := path + ":3: [NotFound] Vegitable 'Beans' is not found!"
+	if got := located(path, 3, &render.Error{Code: "NotFound", Message: "Vegitable 'Beans' is not found!"}).Error(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
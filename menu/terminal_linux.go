@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package menu
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd uintptr, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Whether fd is a terminal the line editor can drive
+func isTerminal(fd uintptr) bool {
+	var termios syscall.Termios
+	return ioctl(fd, syscall.TCGETS, &termios) == nil
+}
+
+// Raise SIGINT, as Ctrl-C does out of raw mode
+func interrupt() {
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+}
+
+// Put the terminal in raw mode, keys are read one by one
+// without being echoed and Ctrl-C is just another key.
+// Output is left as it is, so "\n" still starts a new
+// line. The returned function restores the terminal.
+func makeRaw(fd uintptr) (restore func(), err error) {
+	var old syscall.Termios
+	if err = ioctl(fd, syscall.TCGETS, &old); err != nil {
+		return
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err = ioctl(fd, syscall.TCSETS, &raw); err != nil {
+		return
+	}
+
+	restore = func() {
+		ioctl(fd, syscall.TCSETS, &old)
+	}
+	return
+}
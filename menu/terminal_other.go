@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package menu
+
+import "errors"
+
+// Without raw mode the menu falls back to reading whole
+// lines, as it does from a pipe.
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+// Never needed, there is no raw mode to read Ctrl-C in
+func interrupt() {}
+
+func makeRaw(fd uintptr) (restore func(), err error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
@@ -0,0 +1,335 @@
+// render writes the results of the client and server
+// menus in the output format the user picked.
+//
+// Every result is one of a few shapes (a Table of rows, a
+// Record of fields, a Message or an Error) so that each of
+// them looks the same whatever view it comes from:
+//
+//	table   tables drawn for people (the default)
+//	json    a JSON value per result
+//	csv     comma separated rows with a header
+//	yaml    a YAML document per result
+package render
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// Format is the way results are written.
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+	FormatYAML  Format = "yaml"
+)
+
+// Formats lists every format.
+var Formats = []Format{FormatTable, FormatJSON, FormatCSV, FormatYAML}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+
+	return "", errors.New("Unknown output format '" + name + "'! It should be one of table, json, csv or yaml.")
+}
+
+// Column describes a column of a table. Title heads it in
+// a table, Key names its values in the other formats. The
+// values of a Literal column (numbers, true or false) are
+// written as they are rather than as strings.
+type Column struct {
+	Title   string
+	Key     string
+	Literal bool
+}
+
+// Field is a single named value, e.g. of a Record or the
+// total of a table.
+type Field struct {
+	Column
+	Value string
+}
+
+// Table is a list of rows, each with a value per column.
+// Totals sum the rows up, a table shows them in its
+// footer.
+type Table struct {
+	Columns []Column
+	Rows    [][]string
+	Totals  []Field
+
+	// NoWrap keeps long values on a single line of a
+	// table.
+	NoWrap bool
+}
+
+// Record is a single set of fields.
+type Record []Field
+
+// Error is an error with the code the server failed with,
+// if any, and the details of what went wrong, e.g. the
+// lines of a rejected order. Location tells where the
+// command that failed came from, e.g. the line of a
+// script.
+type Error struct {
+	Code     string
+	Message  string
+	Details  *Table
+	Location string
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if e.Code != "" {
+		message = "[" + e.Code + "] " + message
+	}
+	if e.Location != "" {
+		message = e.Location + ": " + message
+	}
+
+	if e.Details != nil {
+		var details strings.Builder
+
+		writeTable(&details, *e.Details)
+		message += "\n" + strings.TrimSuffix(details.String(), "\n")
+	}
+
+	return message
+}
+
+// Renderer writes results to Out in Format. Its methods
+// are safe for concurrent use.
+type Renderer struct {
+	Format Format
+	Out    io.Writer
+
+	mutex   sync.Mutex
+	written bool
+}
+
+// New returns a renderer writing to out in format, the
+// table format when it is empty.
+func New(out io.Writer, format Format) *Renderer {
+	if format == "" {
+		format = FormatTable
+	}
+
+	return &Renderer{Format: format, Out: out}
+}
+
+// Structured tells whether the format is meant for other
+// programs rather than people, so anything else printed
+// along with the results (progress, prompts) should go
+// elsewhere.
+func (r *Renderer) Structured() bool {
+	return r.format() != FormatTable
+}
+
+func (r *Renderer) format() Format {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.Format == "" {
+		return FormatTable
+	}
+
+	return r.Format
+}
+
+// SetFormat changes the format by its name.
+func (r *Renderer) SetFormat(name string) error {
+	format, err := ParseFormat(name)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Format = format
+	return nil
+}
+
+// Table writes the rows of a table.
+func (r *Renderer) Table(t Table) {
+	r.write(func(w io.Writer, format Format) {
+		switch format {
+		case FormatJSON:
+			writeJSON(w, tableValue(t))
+		case FormatCSV:
+			writeCSV(w, t)
+		case FormatYAML:
+			writeYAML(w, tableValue(t))
+		default:
+			writeTable(w, t)
+		}
+	})
+}
+
+// Record writes the fields of a record.
+func (r *Renderer) Record(record Record) {
+	r.write(func(w io.Writer, format Format) {
+		switch format {
+		case FormatJSON:
+			writeJSON(w, recordValue(record))
+		case FormatCSV:
+			writeCSV(w, recordTable(record))
+		case FormatYAML:
+			writeYAML(w, recordValue(record))
+		default:
+			for _, f := range record {
+				fmt.Fprintln(w, f.Title+": "+f.Value)
+			}
+		}
+	})
+}
+
+// Message writes the message of a command that
+// succeeded.
+func (r *Renderer) Message(message string) {
+	if !r.Structured() {
+		r.write(func(w io.Writer, format Format) {
+			fmt.Fprintln(w, message)
+		})
+		return
+	}
+
+	r.Record(Record{
+		{Column{Title: "Ok", Key: "ok", Literal: true}, "true"},
+		{Column{Title: "Message", Key: "message"}, message},
+	})
+}
+
+// Error writes why a command failed. An *Error keeps its
+// code and details, any other error is just its message.
+func (r *Renderer) Error(err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Message: err.Error()}
+	}
+
+	record := Record{
+		{Column{Title: "Ok", Key: "ok", Literal: true}, "false"},
+		{Column{Title: "Code", Key: "code"}, e.Code},
+		{Column{Title: "Message", Key: "message"}, e.Message},
+	}
+	if e.Location != "" {
+		record = append(record, Field{Column{Title: "Location", Key: "location"}, e.Location})
+	}
+
+	r.write(func(w io.Writer, format Format) {
+		switch format {
+		case FormatJSON, FormatYAML:
+			value := recordValue(record)
+			if e.Details != nil {
+				value = append(value, member{"details", tableValue(Table{Columns: e.Details.Columns, Rows: e.Details.Rows})})
+			}
+
+			if format == FormatJSON {
+				writeJSON(w, value)
+			} else {
+				writeYAML(w, value)
+			}
+		case FormatCSV:
+			// the details follow as a table of their own
+			writeCSV(w, recordTable(record))
+			if e.Details != nil {
+				fmt.Fprintln(w)
+				writeCSV(w, *e.Details)
+			}
+		default:
+			fmt.Fprintln(w, err)
+		}
+	})
+}
+
+// write runs a writer of a result in the current format,
+// separating it from the one before in CSV.
+func (r *Renderer) write(result func(w io.Writer, format Format)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	format := r.Format
+	if format == "" {
+		format = FormatTable
+	}
+
+	if r.written && format == FormatCSV {
+		fmt.Fprintln(r.Out)
+	}
+	r.written = true
+
+	result(r.Out, format)
+}
+
+// A record as a table of a single row
+func recordTable(record Record) Table {
+	t := Table{Rows: [][]string{nil}}
+	for _, f := range record {
+		t.Columns = append(t.Columns, f.Column)
+		t.Rows[0] = append(t.Rows[0], f.Value)
+	}
+
+	return t
+}
+
+func writeTable(w io.Writer, t Table) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(!t.NoWrap)
+
+	var header []string
+	for _, c := range t.Columns {
+		header = append(header, c.Title)
+	}
+	table.SetHeader(header)
+	table.AppendBulk(t.Rows)
+
+	if len(t.Totals) > 0 {
+		table.SetFooter(footer(t))
+	}
+	table.Render()
+}
+
+// The totals of a table laid out under its last columns,
+// each value right after its title
+func footer(t Table) []string {
+	cells := make([]string, len(t.Columns))
+
+	i := len(cells) - 1
+	for j := len(t.Totals) - 1; j >= 0 && i >= 1; j-- {
+		cells[i] = t.Totals[j].Value
+		cells[i-1] = t.Totals[j].Title
+		i -= 2
+	}
+
+	return cells
+}
+
+func writeCSV(w io.Writer, t Table) {
+	writer := csv.NewWriter(w)
+
+	var header []string
+	for _, c := range t.Columns {
+		header = append(header, c.Key)
+	}
+	writer.Write(header)
+	writer.WriteAll(t.Rows)
+
+	if len(t.Totals) > 0 {
+		writer.Write(footer(t))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,181 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var (
+	nameColumn  = Column{Title: "Name", Key: "name"}
+	priceColumn = Column{Title: "Unit Price", Key: "pricePerKg", Literal: true}
+)
+
+func stock() Table {
+	return Table{
+		Columns: []Column{nameColumn, priceColumn},
+		Rows:    [][]string{{"Beans", "175.00"}, {"Sweet <Potato>", "120.50"}},
+		Totals:  []Field{{Column{Title: "Total", Key: "total", Literal: true}, "295.50"}},
+	}
+}
+
+func rendered(format Format, result func(r *Renderer)) string {
+	var b bytes.Buffer
+	result(New(&b, format))
+
+	return b.String()
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		result func(r *Renderer)
+		want   string
+	}{
+		{func(r *Renderer) { r.Table(stock()) }, `{
+  "rows": [
+    {
+      "name": "Beans",
+      "pricePerKg": 175.00
+    },
+    {
+      "name": "Sweet \u003cPotato\u003e",
+      "pricePerKg": 120.50
+    }
+  ],
+  "total": 295.50
+}
+`},
+		{func(r *Renderer) { r.Table(Table{Columns: []Column{nameColumn}}) }, "[]\n"},
+		{func(r *Renderer) { r.Message(`done "now"`) }, `{
+  "ok": true,
+  "message": "done \"now\""
+}
+`},
+	}
+
+	for _, test := range tests {
+		if got := rendered(FormatJSON, test.result); got != test.want {
+			t.Errorf("got\n%s\nwant\n%s", got, test.want)
+		}
+	}
+}
+
+func TestLiteralIsCheckedAsJSON(t *testing.T) {
+	got := rendered(FormatJSON, func(r *Renderer) {
+		r.Record(Record{{priceColumn, "12,5"}})
+	})
+
+	if want := "{\n  \"pricePerKg\": \"12,5\"\n}\n"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestYAML(t *testing.T) {
+	got := rendered(FormatYAML, func(r *Renderer) {
+		r.Table(stock())
+		r.Record(Record{{nameColumn, "yes"}, {Column{Title: "No", Key: "no"}, "1e3"}, {Column{Title: "Note", Key: "note"}, "a: b\n# c"}})
+	})
+
+	want := `---
+"rows":
+  - "name": "Beans"
+    "pricePerKg": 175.00
+  - "name": "Sweet \u003cPotato\u003e"
+    "pricePerKg": 120.50
+"total": 295.50
+---
+"name": "yes"
+"no": "1e3"
+"note": "a: b\n# c"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	got := rendered(FormatCSV, func(r *Renderer) {
+		r.Table(stock())
+		r.Message("done, at last")
+	})
+
+	want := `name,pricePerKg
+Beans,175.00
+Sweet <Potato>,120.50
+Total,295.50
+
+ok,message
+true,"done, at last"
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTable(t *testing.T) {
+	got := rendered(FormatTable, func(r *Renderer) { r.Table(stock()) })
+
+	for _, want := range []string{"NAME", "UNIT PRICE", "Sweet <Potato>", "120.50", "TOTAL", "295.50"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("table lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{
+		Code:    "InvalidArgument",
+		Message: "Not enough stocks!",
+		Details: &Table{Columns: []Column{nameColumn, priceColumn}, Rows: [][]string{{"Beans", "175.00"}}},
+	}
+
+	tests := []struct {
+		format Format
+		err    error
+		want   string
+	}{
+		{FormatJSON, err, `{
+  "ok": false,
+  "code": "InvalidArgument",
+  "message": "Not enough stocks!",
+  "details": [
+    {
+      "name": "Beans",
+      "pricePerKg": 175.00
+    }
+  ]
+}
+`},
+		{FormatYAML, err, `---
+"ok": false
+"code": "InvalidArgument"
+"message": "Not enough stocks!"
+"details":
+  - "name": "Beans"
+    "pricePerKg": 175.00
+`},
+		{FormatCSV, err, `ok,code,message
+false,InvalidArgument,Not enough stocks!
+
+name,pricePerKg
+Beans,175.00
+`},
+		{FormatJSON, errors.New("Unknown command"), `{
+  "ok": false,
+  "code": "",
+  "message": "Unknown command"
+}
+`},
+	}
+
+	for _, test := range tests {
+		if got := rendered(test.format, func(r *Renderer) { r.Error(test.err) }); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.format, got, test.want)
+		}
+	}
+
+	if got := err.Error(); !strings.HasPrefix(got, "[InvalidArgument] Not enough stocks!\n") || !strings.Contains(got, "Beans") {
+		t.Errorf("got %q, want the code, message and details", got)
+	}
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The values JSON and YAML are written from: a string, a
+// literal, a list or an object keeping the order of its
+// members.
+type (
+	literal string
+	list    []interface{}
+	object  []member
+	member  struct {
+		key   string
+		value interface{}
+	}
+)
+
+func value(c Column, v string) interface{} {
+	if c.Literal && json.Valid([]byte(v)) && !strings.HasPrefix(v, `"`) {
+		return literal(v)
+	}
+
+	return v
+}
+
+func recordValue(record Record) (o object) {
+	for _, f := range record {
+		o = append(o, member{f.Key, value(f.Column, f.Value)})
+	}
+
+	return
+}
+
+// The rows of a table as a list of objects, along with
+// the totals when it has any
+func tableValue(t Table) interface{} {
+	rows := list{}
+	for _, row := range t.Rows {
+		var o object
+		for i, c := range t.Columns {
+			if i < len(row) {
+				o = append(o, member{c.Key, value(c, row[i])})
+			}
+		}
+
+		rows = append(rows, o)
+	}
+
+	if len(t.Totals) == 0 {
+		return rows
+	}
+
+	return append(object{{"rows", rows}}, recordValue(Record(t.Totals))...)
+}
+
+func (l literal) MarshalJSON() ([]byte, error) {
+	return []byte(l), nil
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+func writeJSON(w io.Writer, v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(w, string(b))
+}
+
+// Every value is written as its own YAML document. Keys
+// and strings are always double-quoted, a JSON string
+// being a valid YAML one, so that none of them reads back
+// as anything else (e.g. "yes" as true or "1e3" as a
+// number). Literals are written as they are.
+func writeYAML(w io.Writer, v interface{}) {
+	fmt.Fprintln(w, "---")
+	yamlValue(w, v, "")
+}
+
+// Write a value that starts a line (or follows "key:")
+// with the given indent for its nested lines
+func yamlValue(w io.Writer, v interface{}, indent string) {
+	switch v := v.(type) {
+	case object:
+		if len(v) == 0 {
+			fmt.Fprintln(w, "{}")
+			return
+		}
+
+		for i, m := range v {
+			if i > 0 {
+				fmt.Fprint(w, indent)
+			}
+			fmt.Fprint(w, yamlString(m.key)+":")
+
+			switch m.value.(type) {
+			case object, list:
+				if isEmpty(m.value) {
+					fmt.Fprint(w, " ")
+				} else {
+					fmt.Fprint(w, "\n"+indent+"  ")
+				}
+				yamlValue(w, m.value, indent+"  ")
+			default:
+				fmt.Fprint(w, " ")
+				yamlValue(w, m.value, indent+"  ")
+			}
+		}
+	case list:
+		if len(v) == 0 {
+			fmt.Fprintln(w, "[]")
+			return
+		}
+
+		for i, item := range v {
+			if i > 0 {
+				fmt.Fprint(w, indent)
+			}
+			fmt.Fprint(w, "- ")
+			yamlValue(w, item, indent+"  ")
+		}
+	case literal:
+		fmt.Fprintln(w, string(v))
+	case string:
+		fmt.Fprintln(w, yamlString(v))
+	}
+}
+
+func isEmpty(v interface{}) bool {
+	switch v := v.(type) {
+	case object:
+		return len(v) == 0
+	case list:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
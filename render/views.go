@@ -0,0 +1,109 @@
+package render
+
+import (
+	"strconv"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// The columns the views below share
+var (
+	NameColumn     = Column{Title: "Vegitable Name", Key: "name"}
+	PriceColumn    = Column{Title: "Unit Price", Key: "pricePerKg", Literal: true}
+	StocksColumn   = Column{Title: "Stocks(KG)", Key: "remainingKgs", Literal: true}
+	VersionColumn  = Column{Title: "Version", Key: "version", Literal: true}
+	QuantityColumn = Column{Title: "Quantity(KG)", Key: "kgs", Literal: true}
+)
+
+// Vegitables is the table of the unit price, stocks and
+// version of vegitables.
+func Vegitables(vegitables []core.Vegitable) Table {
+	t := Table{Columns: []Column{NameColumn, PriceColumn, StocksColumn, VersionColumn}}
+
+	for _, v := range vegitables {
+		t.Rows = append(t.Rows, []string{v.Name, v.PricePerKg.String(), v.RemainingKgs.String(), strconv.FormatUint(v.Version, 10)})
+	}
+
+	return t
+}
+
+// Receipt is the table of the lines of a receipt along
+// with its total.
+func Receipt(receipt core.Receipt) Table {
+	t := Table{
+		Columns: []Column{NameColumn, QuantityColumn, PriceColumn, {Title: "Amount", Key: "amount", Literal: true}},
+		Totals:  []Field{{Column{Title: "Total", Key: "total", Literal: true}, receipt.Total.String()}},
+	}
+
+	for _, l := range receipt.Lines {
+		t.Rows = append(t.Rows, []string{l.Name, l.Kgs.String(), l.PricePerKg.String(), l.Amount.String()})
+	}
+
+	return t
+}
+
+// LineErrors is the table of the reasons why the lines of
+// an order were rejected.
+func LineErrors(lineErrors []core.OrderLineError) Table {
+	t := Table{Columns: []Column{
+		{Title: "Line", Key: "line", Literal: true},
+		NameColumn,
+		{Title: "Code", Key: "code"},
+		{Title: "Reason", Key: "reason"},
+	}}
+
+	for _, e := range lineErrors {
+		t.Rows = append(t.Rows, []string{strconv.Itoa(e.Line + 1), e.Name, e.Code.String(), e.Reason})
+	}
+
+	return t
+}
+
+// AuditEntries is the table of who changed vegitables,
+// when and how.
+func AuditEntries(entries []core.AuditEntry) Table {
+	t := Table{
+		Columns: []Column{
+			{Title: "Time", Key: "time"},
+			{Title: "Actor", Key: "actor"},
+			{Title: "Connection", Key: "connection"},
+			{Title: "Action", Key: "action"},
+			{Title: "Before", Key: "before"},
+			{Title: "After", Key: "after"},
+		},
+		NoWrap: true,
+	}
+
+	for _, e := range entries {
+		t.Rows = append(t.Rows, []string{
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.Actor,
+			e.Connection,
+			e.Action,
+			describe(e.Old),
+			describe(e.New),
+		})
+	}
+
+	return t
+}
+
+// describe summarizes a vegitable within a single cell.
+func describe(v *core.Vegitable) string {
+	if v == nil {
+		return "-"
+	}
+
+	return v.Name + ": " + v.PricePerKg.String() + " per KG, " + v.RemainingKgs.String() + " KG"
+}
+
+// Failure is the error of a response that failed with
+// code, the code is left out when the response has none.
+func Failure(code core.ErrorCode, message string) *Error {
+	e := &Error{Message: message}
+	if code != core.CodeOK {
+		e.Code = code.String()
+	}
+
+	return e
+}
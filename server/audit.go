@@ -0,0 +1,254 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+const (
+	// DefaultAuditMaxSize is the size in bytes the audit
+	// log grows to before it is rotated when
+	// AuditLog.MaxSize is not set.
+	DefaultAuditMaxSize = 10 << 20
+
+	// DefaultAuditBackups is the number of rotated audit
+	// logs kept when AuditLog.Backups is not set.
+	DefaultAuditBackups = 5
+)
+
+// AuditLog is an append-only JSON-lines file with one
+// core.AuditEntry per line.
+//
+// Once the file would grow past MaxSize it is renamed to
+// `<path>.1`, the older ones shift to `<path>.2` and so on
+// up to Backups files, and a new file is started.
+type AuditLog struct {
+	MaxSize int64
+	Backups int
+
+	path  string
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// OpenAuditLog opens the audit log at path, creating it
+// when it does not exist yet.
+func OpenAuditLog(path string) (a *AuditLog, err error) {
+	a = &AuditLog{path: path}
+
+	err = a.open()
+	return
+}
+
+// open starts writing to the file at path, the file is
+// nil when it cannot.
+func (a *AuditLog) open() (err error) {
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		a.file = nil
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		a.file = nil
+		return
+	}
+
+	a.file, a.size = file, info.Size()
+	return
+}
+
+// Record appends an entry, rotating the file first when
+// it is full. The entry is synced to disk before Record
+// returns.
+//
+// A failed rotation is only logged, the entry goes to the
+// file at path all the same. When even that cannot be
+// opened the next entry tries again.
+func (a *AuditLog) Record(entry core.AuditEntry) (err error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file == nil {
+		err = a.open()
+		if err != nil {
+			return
+		}
+	}
+
+	maxSize := a.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultAuditMaxSize
+	}
+
+	if a.size > 0 && a.size+int64(len(line)) > maxSize {
+		if rerr := a.rotate(); rerr != nil {
+			if a.file == nil {
+				return rerr
+			}
+
+			log.Println("cannot rotate audit log:", rerr)
+		}
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return
+	}
+
+	return a.file.Sync()
+}
+
+// rotate shifts the backups by one and starts a new file.
+// Whatever fails the file at path is opened again, the
+// full one when it could not be moved. The caller must
+// hold the mutex.
+func (a *AuditLog) rotate() (err error) {
+	defer func() {
+		if oerr := a.open(); err == nil {
+			err = oerr
+		}
+	}()
+
+	err = a.file.Close()
+	if err != nil {
+		return
+	}
+
+	backups := a.backups()
+
+	// the oldest one falls off the end.
+	os.Remove(a.backup(backups))
+	for i := backups - 1; i >= 1; i-- {
+		err = os.Rename(a.backup(i), a.backup(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return
+		}
+	}
+
+	return os.Rename(a.path, a.backup(1))
+}
+
+func (a *AuditLog) backups() int {
+	if a.Backups <= 0 {
+		return DefaultAuditBackups
+	}
+
+	return a.Backups
+}
+
+func (a *AuditLog) backup(i int) string {
+	return a.path + "." + strconv.Itoa(i)
+}
+
+// History returns the entries of the named vegitable,
+// oldest first. A vegitable is followed through its
+// renames, so the entries recorded under its former names
+// are included as well.
+func (a *AuditLog) History(name string) (entries []core.AuditEntry, err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var all []core.AuditEntry
+	for i := a.backups(); i >= 0; i-- {
+		path := a.path
+		if i > 0 {
+			path = a.backup(i)
+		}
+
+		all, err = readAudit(path, all)
+		if err != nil {
+			return
+		}
+	}
+
+	// walking backwards, a rename to the name swaps it
+	// for the name the vegitable had before and the
+	// addition of the vegitable ends its history.
+	names := map[string]bool{name: true}
+	for i := len(all) - 1; i >= 0; i-- {
+		entry := all[i]
+
+		touches := (entry.Old != nil && names[entry.Old.Name]) ||
+			(entry.New != nil && names[entry.New.Name])
+		if !touches {
+			continue
+		}
+
+		if entry.New != nil {
+			delete(names, entry.New.Name)
+		}
+		if entry.Old != nil {
+			names[entry.Old.Name] = true
+		}
+
+		entries = append(entries, entry)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return
+}
+
+// readAudit appends the entries of an audit file to
+// entries. A missing file has none.
+//
+// Every line that cannot be decoded is skipped with a
+// warning, wherever it is: a line torn by a crash is not
+// only the last one for long, the next entry is appended
+// to it.
+func readAudit(path string, entries []core.AuditEntry) ([]core.AuditEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+
+	for number := 1; scanner.Scan(); number++ {
+		var entry core.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("%s:%d: skipping a corrupt audit entry: %v\n", path, number, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Close closes the file of the audit log.
+func (a *AuditLog) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+
+	return a.file.Close()
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+func entry(name string, stocks core.Weight) core.AuditEntry {
+	return core.AuditEntry{
+		Time:   time.Now(),
+		Actor:  "root",
+		Action: "update stocks",
+		Name:   name,
+		Old:    &core.Vegitable{Name: name, RemainingKgs: stocks - 1},
+		New:    &core.Vegitable{Name: name, RemainingKgs: stocks},
+	}
+}
+
+func TestAuditLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	a.MaxSize = 200
+	a.Backups = 2
+
+	for i := 0; i < 10; i++ {
+		if err := a.Record(entry("Beans", core.Weight(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s is missing: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more backups were kept than asked for: %v", err)
+	}
+
+	// the history reads the backups too, oldest first
+	entries, err := a.History("Beans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) < 2 || entries[len(entries)-1].New.RemainingKgs != 9 {
+		t.Fatalf("got %d entries, want the latest last", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].New.RemainingKgs <= entries[i-1].New.RemainingKgs {
+			t.Fatalf("entries are out of order: %v after %v", entries[i].New.RemainingKgs, entries[i-1].New.RemainingKgs)
+		}
+	}
+}
+
+// A rotation that fails leaves the log writing to its
+// file rather than closed for good.
+func TestAuditLogRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	a.MaxSize = 1
+	a.Backups = 1
+
+	// nothing can be renamed over a directory that is
+	// not empty
+	if err := os.MkdirAll(filepath.Join(path+".1", "in-the-way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := a.Record(entry("Beans", core.Weight(i))); err != nil {
+			t.Fatalf("record %d failed: %v", i, err)
+		}
+	}
+
+	entries, err := readAudit(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries in %s, want 3", len(entries), path)
+	}
+}
+
+// A line torn by a crash gets the next entry appended to
+// it, the entries around it are still read.
+func TestReadAuditSkipsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err := a.Record(entry("Beans", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"Time":"2024-`)
+	file.Close()
+
+	for _, stocks := range []core.Weight{2, 3} {
+		if err := a.Record(entry("Beans", stocks)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := readAudit(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].New.RemainingKgs != 1 || entries[1].New.RemainingKgs != 3 {
+		t.Fatalf("got %d entries, want the first and the last", len(entries))
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// DefaultSessionTTL is how long a session lasts when
+// Auth.SessionTTL is not set.
+const DefaultSessionTTL = 12 * time.Hour
+
+// identity is who is behind an API token or a session.
+type identity struct {
+	Name string
+	Role core.Role
+}
+
+// anonymous is the identity of everyone when the server
+// runs without authentication.
+var anonymous = identity{Name: "anonymous", Role: core.RoleAdmin}
+
+// operator is the identity of whoever uses the server
+// menu.
+var operator = identity{Name: "operator", Role: core.RoleAdmin}
+
+type session struct {
+	identity
+	expires time.Time
+}
+
+// Auth authenticates clients with API tokens and keeps
+// the sessions handed out by V1.Login.
+type Auth struct {
+	// SessionTTL is how long a session is valid
+	// after login.
+	SessionTTL time.Duration
+
+	tokens   map[string]identity
+	mutex    sync.Mutex
+	sessions map[string]session
+}
+
+// LoadAuth reads the API tokens from a file with one
+// `<token> <role> <name>` entry per line. Empty lines and
+// lines starting with '#' are ignored.
+func LoadAuth(path string) (a *Auth, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	a = &Auth{
+		tokens:   make(map[string]identity),
+		sessions: make(map[string]session),
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 || !core.Role(fields[1]).Valid() {
+			err = errors.New("auth: " + path + ":" + strconv.Itoa(line) + ": expected '<token> <customer|clerk|admin> <name>'")
+			return
+		}
+
+		a.tokens[fields[0]] = identity{Name: fields[2], Role: core.Role(fields[1])}
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// login opens a session for the API token.
+func (a *Auth) login(token string) (id string, who identity, ok bool) {
+	who, ok = a.tokens[token]
+	if !ok {
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		ok = false
+		return
+	}
+	id = hex.EncodeToString(buf)
+
+	ttl := a.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.expire()
+	a.sessions[id] = session{identity: who, expires: time.Now().Add(ttl)}
+	return
+}
+
+// identify returns who owns a live session.
+func (a *Auth) identify(id string) (who identity, ok bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	s, ok := a.sessions[id]
+	if !ok || time.Now().After(s.expires) {
+		delete(a.sessions, id)
+		return identity{}, false
+	}
+
+	return s.identity, true
+}
+
+// expire drops the sessions past their expiry. The caller
+// must hold the mutex.
+func (a *Auth) expire() {
+	now := time.Now()
+	for id, s := range a.sessions {
+		if now.After(s.expires) {
+			delete(a.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/rpc"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/client"
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// loadAuth reads tokens for a customer and an admin.
+func loadAuth(t *testing.T) *Auth {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tokens.txt")
+	err := ioutil.WriteFile(path, []byte("t-ann customer ann\nt-root admin root\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := LoadAuth(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+// The legacy string commands are served under auth to the
+// roles their V1 counterparts allow.
+func TestLegacyHandlerUnderAuth(t *testing.T) {
+	port := start(t, &Server{Auth: loadAuth(t)})
+
+	conn, err := rpc.Dial("tcp", "127.0.0.1:"+strconv.Itoa(int(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var login core.SessionResponse
+	if err := conn.Call("V1.Login", core.LoginRequest{Token: "t-ann"}, &login); err != nil || !login.Ok {
+		t.Fatalf("login failed: %v %+v", err, login.Status)
+	}
+
+	tests := []struct {
+		method  string
+		session string
+		command []string
+		want    core.ErrorCode
+	}{
+		{"Handler.CshowVegitable", login.Session, []string{"vegitable", "Beans"}, core.CodeOK},
+		{"Handler.CshowVegitable", "", []string{"vegitable", "Beans"}, core.CodeUnauthorized},
+		{"Handler.Purchase", login.Session, []string{"Beans", "1"}, core.CodeOK},
+		{"Handler.CaddVegitable", login.Session, []string{"vegitable", "Leeks", "300", "2"}, core.CodeUnauthorized},
+	}
+
+	for _, test := range tests {
+		var res core.Response
+
+		req := core.Request{Credentials: core.Credentials{Session: test.session}, Command: test.command}
+		if err := conn.Call(test.method, req, &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Code != test.want {
+			t.Errorf("%s %v: got %v (%s), want %v", test.method, test.command, res.Code, res.Message, test.want)
+		}
+	}
+}
+
+// A client logs in again once the server forgot its
+// session, the answer of the retry is not mixed up with
+// the rejected one.
+func TestLoginAgainAfterSessionIsForgotten(t *testing.T) {
+	a := loadAuth(t)
+	port := start(t, &Server{Auth: a})
+
+	c := &client.Client{Host: "127.0.0.1", Port: port, Token: "t-root", Retries: -1}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	a.mutex.Lock()
+	a.sessions = make(map[string]session)
+	a.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := c.WhoAmI(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Ok || res.Code != core.CodeOK || res.Name != "root" {
+		t.Fatalf("got %+v, want root with no error code", res)
+	}
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Connection describes a client connection accepted by
+// the server.
+type Connection struct {
+	ID        uint64
+	Remote    string
+	Transport string
+	Since     time.Time
+}
+
+// ConnStats accounts for the connections of the server
+// since it started.
+type ConnStats struct {
+	Active   []Connection
+	Accepted uint64
+	Rejected uint64
+}
+
+// connTable keeps track of the open connections. Once
+// closed it closes them all and refuses new ones.
+type connTable struct {
+	mutex    sync.Mutex
+	active   map[uint64]*Connection
+	conns    map[uint64]net.Conn
+	nextID   uint64
+	accepted uint64
+	rejected uint64
+	closed   bool
+}
+
+// open registers a new connection unless max (when
+// positive) connections are already open or the table is
+// closed.
+func (t *connTable) open(conn net.Conn, max int) (*Connection, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.closed {
+		return nil, false
+	}
+
+	if max > 0 && len(t.active) >= max {
+		t.rejected++
+		return nil, false
+	}
+
+	if t.active == nil {
+		t.active = make(map[uint64]*Connection)
+		t.conns = make(map[uint64]net.Conn)
+	}
+
+	t.nextID++
+	t.accepted++
+
+	c := &Connection{
+		ID:     t.nextID,
+		Remote: conn.RemoteAddr().String(),
+		Since:  time.Now(),
+	}
+	t.active[c.ID] = c
+	t.conns[c.ID] = conn
+
+	return c, true
+}
+
+func (t *connTable) close(id uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.active, id)
+	delete(t.conns, id)
+}
+
+// closeAll closes every open connection, the goroutines
+// serving them then see them fail and return.
+func (t *connTable) closeAll() {
+	t.mutex.Lock()
+	t.closed = true
+
+	conns := make([]net.Conn, 0, len(t.conns))
+	for _, conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.mutex.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (t *connTable) setTransport(id uint64, transport string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if c, ok := t.active[id]; ok {
+		c.Transport = transport
+	}
+}
+
+func (t *connTable) stats() (stats ConnStats) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, c := range t.active {
+		stats.Active = append(stats.Active, *c)
+	}
+	sort.Slice(stats.Active, func(i, j int) bool {
+		return stats.Active[i].ID < stats.Active[j].ID
+	})
+
+	stats.Accepted = t.accepted
+	stats.Rejected = t.rejected
+	return
+}
+
+// ConnStats returns the connections currently open along
+// with the totals accepted and rejected.
+func (s *Server) ConnStats() ConnStats {
+	return s.conns.stats()
+}
+
+// track registers an accepted connection, closing it
+// right away when the server is already at MaxConns.
+func (s *Server) track(conn net.Conn) (*trackedConn, bool) {
+	c, ok := s.conns.open(conn, s.MaxConns)
+	if !ok && s.isClosed() {
+		conn.Close()
+		return nil, false
+	}
+	if !ok {
+		log.Printf("rejecting connection from %s: %d connections open\n", conn.RemoteAddr(), s.MaxConns)
+		conn.Close()
+		return nil, false
+	}
+
+	tracked := &trackedConn{
+		Conn:    conn,
+		id:      c.ID,
+		idle:    s.IdleTimeout,
+		onClose: s.conns.close,
+	}
+	tracked.touch()
+
+	return tracked, true
+}
+
+// trackedConn removes itself from the connection table
+// once closed and, with an idle timeout, is closed by the
+// deadline when no byte is read or written for that long.
+type trackedConn struct {
+	net.Conn
+	id      uint64
+	idle    time.Duration
+	onClose func(id uint64)
+	once    sync.Once
+}
+
+// touch pushes the deadline of the connection further.
+func (c *trackedConn) touch() {
+	if c.idle > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.idle))
+	}
+}
+
+// limit gives the client d to send something, or the
+// idle timeout when it is shorter, until unlimit.
+func (c *trackedConn) limit(d time.Duration) {
+	if c.idle > 0 && c.idle < d {
+		d = c.idle
+	}
+
+	c.Conn.SetDeadline(time.Now().Add(d))
+}
+
+// unlimit lifts the limit leaving only the idle timeout.
+func (c *trackedConn) unlimit() {
+	c.Conn.SetDeadline(time.Time{})
+	c.touch()
+}
+
+func (c *trackedConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		c.touch()
+	}
+
+	return
+}
+
+func (c *trackedConn) Write(p []byte) (n int, err error) {
+	c.touch()
+	n, err = c.Conn.Write(p)
+	return
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.onClose(c.id)
+	})
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// Handler holds the original string-command methods
+// (e.g. "Handler.CshowVegitable"). They are kept as a
+// compatibility shim for clients that predate the typed V1
+// API: every method validates the positional arguments,
+// translates them to the matching V1 request and copies
+// the outcome back into a core.Response, whose vegitables
+// keep their amounts as text.
+//
+// The session of a request is passed on, so a legacy
+// method needs the same role as its V1 counterpart.
+type Handler struct {
+	v1 *V1
+}
+
+// respond copies the status of a typed response into the
+// legacy response.
+func respond(res *core.Response, status core.Status) {
+	res.Ok = status.Ok
+	res.Code = status.Code
+	res.Message = status.Message
+}
+
+// checkCommand returns an error for an empty command, the
+// only case the legacy methods reported as an RPC error.
+func checkCommand(req core.Request) error {
+	if len(req.Command) < 1 || req.Command[0] == "" {
+		return errors.New("Command shoud be specified!")
+	}
+
+	return nil
+}
+
+// parsePrice validates a unit price given as text.
+func parsePrice(s string) (price core.Money, err error) {
+	price, err = core.ParseMoney(s)
+	if err != nil || price < 0 {
+		err = errors.New("Invalid unit price '" + s + "'!")
+	}
+
+	return
+}
+
+// parseStocks validates a stock amount in KG given as text.
+func parseStocks(s string) (stocks core.Weight, err error) {
+	stocks, err = core.ParseWeight(s)
+	if err != nil || stocks < 0 {
+		err = errors.New("Invalid stocks(KG) '" + s + "'!")
+	}
+
+	return
+}
+
+func (h *Handler) CshowVegitable(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	switch req.Command[0] {
+	case "vegitable", "price", "stocks":
+	default:
+		respond(res, failed(core.CodeInvalidArgument, "Unknown command format: 'show "+req.Command[0]+"'"))
+		return
+	}
+
+	if len(req.Command) != 2 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'show "+req.Command[0]+"' command!"))
+		return
+	}
+
+	if req.Command[0] == "vegitable" && req.Command[1] == "all" {
+		var r core.VegitablesResponse
+
+		err = h.v1.ListVegitables(core.ListVegitablesRequest{Credentials: req.Credentials}, &r)
+		respond(res, r.Status)
+		res.Vegitables = core.Legacy(r.Vegitables...)
+		return
+	}
+
+	var r core.VegitableResponse
+
+	err = h.v1.GetVegitable(core.GetVegitableRequest{Credentials: req.Credentials, Name: req.Command[1]}, &r)
+	respond(res, r.Status)
+	if r.Ok {
+		res.Vegitables = core.Legacy(r.Vegitable)
+	}
+	return
+}
+
+func (h *Handler) CaddVegitable(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	if req.Command[0] != "vegitable" {
+		respond(res, failed(core.CodeInvalidArgument, "Unknown command format: 'add "+req.Command[0]+"'"))
+		return
+	}
+
+	if len(req.Command) != 4 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'add vegitable' command!"))
+		return
+	}
+
+	price, err := parsePrice(req.Command[2])
+	if err != nil {
+		respond(res, failed(core.CodeInvalidArgument, err.Error()))
+		return nil
+	}
+
+	stocks, err := parseStocks(req.Command[3])
+	if err != nil {
+		respond(res, failed(core.CodeInvalidArgument, err.Error()))
+		return nil
+	}
+
+	var r core.VegitableResponse
+
+	err = h.v1.AddVegitable(core.AddVegitableRequest{Credentials: req.Credentials, Vegitable: core.Vegitable{
+		Name:         req.Command[1],
+		PricePerKg:   price,
+		RemainingKgs: stocks,
+	}}, &r)
+	respond(res, r.Status)
+	return
+}
+
+func (h *Handler) CupdateVegitable(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	if req.Command[0] != "price" && req.Command[0] != "stocks" {
+		respond(res, failed(core.CodeInvalidArgument, "Unknown command format: 'update "+req.Command[0]+"'"))
+		return
+	}
+
+	if len(req.Command) != 3 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'update "+req.Command[0]+"' command!"))
+		return
+	}
+
+	var r core.VegitableResponse
+
+	if req.Command[0] == "price" {
+		var price core.Money
+
+		price, err = parsePrice(req.Command[2])
+		if err != nil {
+			respond(res, failed(core.CodeInvalidArgument, err.Error()))
+			return nil
+		}
+
+		err = h.v1.UpdatePrice(core.UpdatePriceRequest{Credentials: req.Credentials, Name: req.Command[1], PricePerKg: price}, &r)
+	} else {
+		var stocks core.Weight
+
+		stocks, err = parseStocks(req.Command[2])
+		if err != nil {
+			respond(res, failed(core.CodeInvalidArgument, err.Error()))
+			return nil
+		}
+
+		err = h.v1.UpdateStocks(core.UpdateStocksRequest{Credentials: req.Credentials, Name: req.Command[1], RemainingKgs: stocks}, &r)
+	}
+
+	respond(res, r.Status)
+	return
+}
+
+func (h *Handler) CdeleteVegitable(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	if req.Command[0] != "vegitable" {
+		respond(res, failed(core.CodeInvalidArgument, "Unknown command format: 'delete "+req.Command[0]+"'"))
+		return
+	}
+
+	if len(req.Command) != 2 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'delete vegitable' command!"))
+		return
+	}
+
+	var r core.VegitableResponse
+
+	err = h.v1.DeleteVegitable(core.DeleteVegitableRequest{Credentials: req.Credentials, Name: req.Command[1]}, &r)
+	respond(res, r.Status)
+	return
+}
+
+func (h *Handler) CrenameVegitable(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	if req.Command[0] != "vegitable" {
+		respond(res, failed(core.CodeInvalidArgument, "Unknown command format: 'rename "+req.Command[0]+"'"))
+		return
+	}
+
+	if len(req.Command) != 3 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'rename vegitable' command!"))
+		return
+	}
+
+	var r core.VegitableResponse
+
+	err = h.v1.RenameVegitable(core.RenameVegitableRequest{Credentials: req.Credentials, Name: req.Command[1], NewName: req.Command[2]}, &r)
+	respond(res, r.Status)
+	return
+}
+
+// Purchase sells a quantity of a vegitable. The command is
+// `<vegitable name> <kgs>`.
+func (h *Handler) Purchase(req core.Request, res *core.Response) (err error) {
+	err = checkCommand(req)
+	if err != nil {
+		return
+	}
+
+	if len(req.Command) != 2 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid number of inputs for 'buy' command!"))
+		return
+	}
+
+	kgs, err := parseStocks(req.Command[1])
+	if err != nil || kgs == 0 {
+		respond(res, failed(core.CodeInvalidArgument, "Invalid quantity(KG) '"+req.Command[1]+"'!"))
+		return nil
+	}
+
+	var r core.ReceiptResponse
+
+	err = h.v1.Purchase(core.PurchaseRequest{Credentials: req.Credentials, Name: req.Command[0], Kgs: kgs}, &r)
+	respond(res, r.Status)
+	res.Receipt = r.Receipt
+	return
+}
+
+// PlaceOrder buys every line of the order or none of
+// them. See V1.PlaceOrder.
+func (h *Handler) PlaceOrder(req core.OrderRequest, res *core.Response) (err error) {
+	var r core.ReceiptResponse
+
+	err = h.v1.PlaceOrder(req, &r)
+	respond(res, r.Status)
+	res.Receipt = r.Receipt
+	res.LineErrors = r.LineErrors
+	return
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strconv"
+	"testing"
+)
+
+// The messages of the legacy API as clients built before
+// the typed API and the decimals still have them.
+type (
+	oldRequest struct {
+		Command []string
+	}
+
+	oldResponse struct {
+		Ok         bool
+		Message    string
+		Vegitables oldVegitables
+	}
+
+	oldVegitables struct {
+		XMLName    xml.Name `xml:"vegitables"`
+		Vegitables []oldVegitable
+	}
+
+	oldVegitable struct {
+		XMLName      xml.Name `xml:"vegitable"`
+		Name         string   `xml:"name"`
+		PricePerKg   string   `xml:"pricePerKg"`
+		RemainingKgs string   `xml:"remainingKgs"`
+	}
+)
+
+func TestLegacyClients(t *testing.T) {
+	port := start(t, &Server{})
+	address := "127.0.0.1:" + strconv.Itoa(int(port))
+
+	dials := []struct {
+		name string
+		dial func() (*rpc.Client, error)
+	}{
+		{"gob", func() (*rpc.Client, error) { return rpc.Dial("tcp", address) }},
+		{"json", func() (*rpc.Client, error) { return jsonrpc.Dial("tcp", address) }},
+	}
+
+	for _, d := range dials {
+		t.Run(d.name, func(t *testing.T) {
+			conn, err := d.dial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			var res oldResponse
+			if err := conn.Call("Handler.CshowVegitable", oldRequest{Command: []string{"vegitable", "all"}}, &res); err != nil {
+				t.Fatal(err)
+			}
+
+			if !res.Ok || len(res.Vegitables.Vegitables) != 1 {
+				t.Fatalf("got %+v, want Beans", res)
+			}
+			if v := res.Vegitables.Vegitables[0]; v.Name != "Beans" || v.PricePerKg != "175.00" || v.RemainingKgs != "10.100" {
+				t.Fatalf("got %+v, want Beans at 175.00 with 10.100 KG", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc/jsonrpc"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sniffTimeout is how long a new connection has to
+// complete the TLS handshake and send its first bytes.
+const sniffTimeout = 10 * time.Second
+
+// httpMethods are the request lines an HTTP client can
+// start a connection with. Neither a gob stream (which
+// starts with a binary length) nor a JSON-RPC one (which
+// starts with '{') can begin like that.
+var httpMethods = [][]byte{
+	[]byte("CONNECT "), []byte("GET "), []byte("POST "), []byte("PUT "),
+	[]byte("HEAD "), []byte("DELETE "), []byte("OPTIONS "), []byte("PATCH "),
+}
+
+// serve accepts connections on l until it is closed and
+// dispatches each of them in its own goroutine, so one
+// client never waits for another one to disconnect.
+func (s *Server) serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		tracked, ok := s.track(conn)
+		if !ok {
+			continue
+		}
+
+		if !s.enter() {
+			tracked.Close()
+			continue
+		}
+
+		go func() {
+			defer s.serving.Done()
+			s.dispatch(tracked)
+		}()
+	}
+}
+
+// dispatch sniffs the first bytes sent by the client to
+// find out which transport it speaks and hands the
+// connection to the matching RPC server:
+//
+//	'{'             JSON-RPC
+//	"<METHOD> "     HTTP (CONNECT for RPC)
+//	anything else   gob
+//
+// With TLS the bytes are sniffed after the handshake.
+// Both have to be over within sniffTimeout, or the idle
+// timeout when it is shorter, so that a client sending
+// nothing does not hold on to its connection.
+func (s *Server) dispatch(conn *trackedConn) {
+	sniffed := &sniffedConn{Conn: conn, reader: bufio.NewReader(conn)}
+
+	conn.limit(sniffTimeout)
+
+	first, err := sniffed.reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	transport := "gob"
+	if first[0] == '{' {
+		transport = "json"
+	} else {
+		// a short peek only fails if the client sent
+		// fewer bytes and is waiting, which no HTTP
+		// client does.
+		head, _ := sniffed.reader.Peek(len("OPTIONS "))
+		for _, method := range httpMethods {
+			if bytes.HasPrefix(head, method) {
+				transport = "http"
+				break
+			}
+		}
+	}
+
+	conn.unlimit()
+
+	s.conns.setTransport(conn.id, transport)
+	sniffed.name = "#" + strconv.FormatUint(conn.id, 10) + " " + transport + " " + conn.RemoteAddr().String()
+
+	if transport == "http" {
+		s.http.push(sniffed)
+		return
+	}
+
+	server, err := s.rpcServer(sniffed.name)
+	if err != nil {
+		log.Println("cannot serve connection:", err)
+		conn.Close()
+		return
+	}
+
+	if transport == "json" {
+		server.ServeCodec(jsonrpc.NewServerCodec(sniffed))
+		return
+	}
+
+	server.ServeConn(sniffed)
+}
+
+// connName is the context key under which the http.Server
+// keeps the name of the connection a request came on.
+type connName struct{}
+
+func withConnName(ctx context.Context, conn net.Conn) context.Context {
+	if sniffed, ok := conn.(*sniffedConn); ok {
+		ctx = context.WithValue(ctx, connName{}, sniffed.name)
+	}
+
+	return ctx
+}
+
+// serveHTTP hands an HTTP connection over to its own RPC
+// server, which hijacks it for the rest of its life.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.enter() {
+		http.Error(w, errClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.serving.Done()
+
+	name, _ := r.Context().Value(connName{}).(string)
+
+	server, err := s.rpcServer(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// sniffedConn is a connection whose first bytes were
+// already read into reader. name describes it in the
+// audit log.
+type sniffedConn struct {
+	net.Conn
+	reader *bufio.Reader
+	name   string
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// connListener is a net.Listener fed with the connections
+// that were identified as HTTP, so that a http.Server can
+// serve them.
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// push hands a connection to the http.Server, closing it
+// if the listener is already closed.
+func (l *connListener) push(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, http.ErrServerClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
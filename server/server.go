@@ -1,19 +1,17 @@
 // server Implements an RPC server that exposes
-// a hello-world to RPC clients.
+// the vegitable inventory to RPC clients.
 //
-// The server is accessible either via HTTP, TCP
-// or JSON-RPC.
+// The server is accessible via HTTP, TCP (gob) and
+// JSON-RPC, all of them on the same port.
 package server
 
 import (
-	"encoding/xml"
+	"crypto/tls"
 	"errors"
-	"fmt"
-	"io/ioutil"
+	"log"
 	"net"
 	"net/http"
 	"net/rpc"
-	"net/rpc/jsonrpc"
 	"os"
 	"strconv"
 	"sync"
@@ -21,423 +19,475 @@ import (
 
 	"github.com/dimalkavindu/go-rpc/core"
 	"github.com/dimalkavindu/go-rpc/menu"
-	"github.com/olekukonko/tablewriter"
+	"github.com/dimalkavindu/go-rpc/render"
+	"github.com/dimalkavindu/go-rpc/store"
 )
 
 // Server holds the configuration used to initiate
 // an RPC server.
+//
+// With Auth set clients have to login and are limited to
+// what their role allows, the server menu is not.
+//
+// With Audit set every change of the inventory is recorded
+// along with who made it and through which connection.
+//
+// Clients may use any transport: the protocol is detected
+// on every connection. ExtraPorts are served exactly like
+// Port.
+//
+// MaxConns (when positive) caps the number of connections
+// open at once, extra ones are closed right away.
+// IdleTimeout (when positive) closes connections that
+// neither send nor receive anything for that long.
 type Server struct {
-	Port     uint
-	UseHttp  bool
-	UseJson  bool
-	Sleep    time.Duration
-	listener net.Listener
+	Port        uint
+	ExtraPorts  []uint
+	MaxConns    int
+	IdleTimeout time.Duration
+	Sleep       time.Duration
+	Store       store.Store
+	TLS         *tls.Config
+	Auth        *Auth
+	Audit       *AuditLog
+	HistoryFile string
+	Output      render.Format
+	out         *render.Renderer
+	v1          *V1
+	mutex       sync.Mutex
+
+	// netMutex guards listeners, http, done and closed:
+	// Close may run on a signal while they are set up.
+	netMutex  sync.Mutex
+	listeners []net.Listener
+	http      *connListener
+	done      chan struct{}
+	closed    bool
+
+	// serving counts the goroutines serving connections,
+	// Close waits for them before closing the store.
+	serving sync.WaitGroup
+
+	console *Handler
+	conns   connTable
+	feed    feed
 }
 
-// Close gracefully terminates the server listener.
+// errClosed is returned when the server is closed before
+// it is done starting.
+var errClosed = errors.New("server closed")
+
+// drainTimeout is how long Close waits for the calls in
+// flight before closing the store anyway.
+const drainTimeout = 5 * time.Second
+
+// Close gracefully terminates the server listeners and
+// the connections still open, waits for the calls in
+// flight and releases the store.
 func (s *Server) Close() (err error) {
-	if s.listener != nil {
-		err = s.listener.Close()
+	s.netMutex.Lock()
+	if !s.closed && s.done != nil {
+		close(s.done)
 	}
+	s.closed = true
 
-	return
-}
+	listeners, http := s.listeners, s.http
+	s.netMutex.Unlock()
 
-var (
-	mutex sync.Mutex
-	// initializing our vegitables array
-	vegitables core.Vegitables
-)
-
-func showVegitable(args ...string) error {
-	if args[0] == "vegitable" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Unit Price", "Stocks(KG)"})
-
-		if args[1] == "all" {
-			for _, v := range vegitables.Vegitables {
-				table.Append([]string{v.Name, v.PricePerKg, v.RemainingKgs})
-			}
-			table.Render()
-			return nil
-		} else {
-			for _, v := range vegitables.Vegitables {
-				if v.Name == args[1] {
-					table.Append([]string{v.Name, v.PricePerKg, v.RemainingKgs})
-					table.Render()
-					return nil
-				}
-			}
-			fmt.Println("Vegitable '" + args[1] + "' is not found!")
-			return nil
+	for _, l := range listeners {
+		if lerr := l.Close(); err == nil {
+			err = lerr
 		}
-	} else if args[0] == "price" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Unit Price"})
-
-		for _, v := range vegitables.Vegitables {
-			if v.Name == args[1] {
-				table.Append([]string{v.Name, v.PricePerKg})
-				table.Render()
-				return nil
-			}
+	}
+
+	if http != nil {
+		http.Close()
+	}
+
+	s.conns.closeAll()
+	s.drain()
+
+	if s.Store != nil {
+		if serr := s.Store.Close(); err == nil {
+			err = serr
 		}
-		fmt.Println("Vegitable '" + args[1] + "' is not found!")
-		return nil
-	} else if args[1] == "stocks" {
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"Vegitable Name", "Stocks(KG)"})
-
-		for _, v := range vegitables.Vegitables {
-			if v.Name == args[1] {
-				table.Append([]string{v.Name, v.RemainingKgs})
-				table.Render()
-				return nil
-			}
+	}
+
+	if s.Audit != nil {
+		if aerr := s.Audit.Close(); err == nil {
+			err = aerr
 		}
-		fmt.Println("Vegitable '" + args[1] + "' is not found!")
-		return nil
-	} else {
-		fmt.Println("Unknown command format: " + args[0] + " " + args[1])
-		return nil
 	}
-	return nil
+
+	return
 }
 
-func addVegitable(args ...string) error {
-	if args[0] == "vegitable" {
-		if len(args) == 4 {
-			for _, v := range vegitables.Vegitables {
-				if v.Name == args[1] {
-					fmt.Println("Cannot add the vegitable! Vegitable '" + v.Name + "' already exists!")
-					return nil
-				}
-			}
-
-			var vegitable core.Vegitable
-			vegitable.Name = args[1]
-			vegitable.PricePerKg = args[2]
-			vegitable.RemainingKgs = args[3]
-
-			mutex.Lock()
-			vegitables.Vegitables = append(vegitables.Vegitables, vegitable)
-			writeToDB()
-			mutex.Unlock()
-			fmt.Println("Vegitable '" + vegitable.Name + "' is successfully added")
-			return nil
-		} else {
-			fmt.Println("Invalid number of inputs for 'add vegitable' command!")
-			return nil
+func (s *Server) showVegitable(args ...string) error {
+	var res core.Response
+
+	err := s.console.CshowVegitable(core.Request{Command: args}, &res)
+	if err != nil {
+		return err
+	}
+
+	if !res.Ok {
+		return render.Failure(res.Code, res.Message)
+	}
+
+	var vegitables []core.Vegitable
+	for _, l := range res.Vegitables.Vegitables {
+		v, err := l.Vegitable()
+		if err != nil {
+			return err
 		}
-	} else {
-		fmt.Println("Unknown command format: 'add " + args[0] + "'")
-		return nil
+
+		vegitables = append(vegitables, v)
 	}
-	return nil
-}
 
-func updateVegitable(args ...string) error {
-	if args[0] == "price" {
-		if len(args) == 3 {
-			for v := 0; v < len(vegitables.Vegitables); v++ {
-				if vegitables.Vegitables[v].Name == args[1] {
-					mutex.Lock()
-					vegitables.Vegitables[v].PricePerKg = args[2]
-					writeToDB()
-					mutex.Unlock()
-					fmt.Println("Vegitable '" + vegitables.Vegitables[v].Name + "' is successfully updated!")
-					return nil
-				}
-			}
-			fmt.Println("Vegitable '" + args[1] + "' is not found!")
-			return nil
-		} else {
-			fmt.Println("Invalid number of inputs for 'update price' command!")
-			return nil
+	switch args[0] {
+	case "vegitable":
+		s.out.Table(render.Vegitables(vegitables))
+	case "price":
+		table := render.Table{Columns: []render.Column{render.NameColumn, render.PriceColumn}}
+		for _, v := range vegitables {
+			table.Rows = append(table.Rows, []string{v.Name, v.PricePerKg.String()})
 		}
-	} else if args[0] == "stocks" {
-		if len(args) == 3 {
-			for v := 0; v < len(vegitables.Vegitables); v++ {
-				if vegitables.Vegitables[v].Name == args[1] {
-					mutex.Lock()
-					vegitables.Vegitables[v].RemainingKgs = args[2]
-					writeToDB()
-					mutex.Unlock()
-					fmt.Println("Vegitable '" + vegitables.Vegitables[v].Name + "' is successfully updated!")
-					return nil
-				}
-			}
-			fmt.Println("Vegitable '" + args[1] + "' is not found!")
-			return nil
-		} else {
-			fmt.Println("Invalid number of inputs for 'update stocks' command!")
-			return nil
+		s.out.Table(table)
+	case "stocks":
+		table := render.Table{Columns: []render.Column{render.NameColumn, render.StocksColumn}}
+		for _, v := range vegitables {
+			table.Rows = append(table.Rows, []string{v.Name, v.RemainingKgs.String()})
 		}
-	} else {
-		fmt.Println("Unknown command format: 'add " + args[0] + "'")
-		return nil
+		s.out.Table(table)
 	}
 
 	return nil
 }
 
-func writeToDB() error {
-	// Open our xmlFile
-	xmlFile, err := os.OpenFile("db.xml", os.O_RDWR|os.O_CREATE, 0755)
+// vegitableNames lists the vegitables for the menu to
+// complete names with.
+func (s *Server) vegitableNames(prefix string) (names []string) {
+	vegitables, err := s.Store.List()
 	if err != nil {
-		fmt.Println(err)
+		return
 	}
 
-	encoder := xml.NewEncoder(xmlFile)
-	err = encoder.Encode(vegitables)
-	if err != nil {
-		fmt.Println(err)
+	for _, v := range vegitables {
+		names = append(names, v.Name)
 	}
 
-	// defer the closing of our xmlFile so that we can parse it later on
-	defer xmlFile.Close()
-
-	return nil
+	return
 }
 
-// Handler holds the methods to be exposed by the RPC
-// server as well as properties that modify the methods'
-// behavior.
-type Handler struct {
-	// Sleep adds a little sleep between to the
-	// method execution to simulate a time-consuming
-	// operation.
-	Sleep time.Duration
+// prefixed passes the words of a subcommand on to a
+// function taking the whole legacy command, e.g.
+// `update price Beans 120` as price, Beans and 120.
+func prefixed(function func(args ...string) error, words ...string) func(args ...string) error {
+	return func(args ...string) error {
+		return function(append(append([]string(nil), words...), args...)...)
+	}
 }
 
-func (h *Handler) CshowVegitable(req core.Request, res *core.Response) (err error) {
-	if req.Command[0] == "" {
-		err = errors.New("Command shoud be specified!")
-		return
+// run executes a legacy command against the console
+// handler, a failure is returned as an error.
+func (s *Server) run(method func(core.Request, *core.Response) error, args []string) error {
+	var res core.Response
+
+	err := method(core.Request{Command: args}, &res)
+	if err != nil {
+		return err
 	}
 
-	if req.Command[0] == "vegitable" {
-		if req.Command[1] == "all" {
-			res.Message = "Command executed successfully!"
-			res.Ok = true
-			res.Vegitables = vegitables
-		} else {
-			for _, v := range vegitables.Vegitables {
-				if v.Name == req.Command[1] {
-					res.Message = "Command executed successfully!"
-					res.Ok = true
-					res.Vegitables.Vegitables = append(res.Vegitables.Vegitables, v)
-					return nil
-				}
-			}
-			res.Message = "Vegitable '" + req.Command[1] + "' is not found!"
-			res.Ok = false
-			return nil
-		}
-	} else if req.Command[0] == "price" || req.Command[0] == "stocks" {
-		for _, v := range vegitables.Vegitables {
-			if v.Name == req.Command[1] {
-				res.Message = "Command executed successfully!"
-				res.Ok = true
-				res.Vegitables.Vegitables = append(res.Vegitables.Vegitables, v)
-				return nil
-			}
-		}
-		res.Message = "Vegitable '" + req.Command[1] + "' is not found!"
-		res.Ok = false
-		return nil
-	} else {
-		res.Message = "Unknown command format: " + req.Command[0] + " " + req.Command[1]
-		res.Ok = false
-		return nil
+	if !res.Ok {
+		return render.Failure(res.Code, res.Message)
 	}
-	if h.Sleep != 0 {
-		time.Sleep(h.Sleep)
+
+	s.out.Message(res.Message)
+	return nil
+}
+
+func (s *Server) showConnections(args ...string) error {
+	stats := s.ConnStats()
+
+	table := render.Table{Columns: []render.Column{
+		{Title: "ID", Key: "id", Literal: true},
+		{Title: "Remote Address", Key: "remote"},
+		{Title: "Transport", Key: "transport"},
+		{Title: "Connected For", Key: "connectedFor"},
+	}}
+
+	for _, c := range stats.Active {
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatUint(c.ID, 10),
+			c.Remote,
+			c.Transport,
+			time.Since(c.Since).Round(time.Second).String(),
+		})
 	}
-	return
+	s.out.Table(table)
+
+	s.out.Record(render.Record{
+		{Column: render.Column{Title: "Open", Key: "open", Literal: true}, Value: strconv.Itoa(len(stats.Active))},
+		{Column: render.Column{Title: "Accepted", Key: "accepted", Literal: true}, Value: strconv.FormatUint(stats.Accepted, 10)},
+		{Column: render.Column{Title: "Rejected", Key: "rejected", Literal: true}, Value: strconv.FormatUint(stats.Rejected, 10)},
+	})
+	return nil
 }
 
-func (h *Handler) CaddVegitable(req core.Request, res *core.Response) (err error) {
-	if req.Command[0] == "" {
-		err = errors.New("Command shoud be specified!")
-		return
+func (s *Server) addVegitable(args ...string) error {
+	return s.run(s.console.CaddVegitable, args)
+}
+
+func (s *Server) updateVegitable(args ...string) error {
+	return s.run(s.console.CupdateVegitable, args)
+}
+
+func (s *Server) deleteVegitable(args ...string) error {
+	return s.run(s.console.CdeleteVegitable, args)
+}
+
+func (s *Server) renameVegitable(args ...string) error {
+	return s.run(s.console.CrenameVegitable, args)
+}
+
+func (s *Server) showHistory(args ...string) error {
+	var res core.HistoryResponse
+
+	err := s.console.v1.History(core.HistoryRequest{Name: args[0]}, &res)
+	if err != nil {
+		return err
 	}
 
-	if req.Command[0] == "vegitable" {
-		if len(req.Command) == 4 {
-			for _, v := range vegitables.Vegitables {
-				if v.Name == req.Command[1] {
-					res.Message = "Cannot add the vegitable! Vegitable '" + v.Name + "' already exists!"
-					res.Ok = false
-					return nil
-				}
-			}
-
-			var vegitable core.Vegitable
-			vegitable.Name = req.Command[1]
-			vegitable.PricePerKg = req.Command[2]
-			vegitable.RemainingKgs = req.Command[3]
-
-			mutex.Lock()
-			vegitables.Vegitables = append(vegitables.Vegitables, vegitable)
-			writeToDB()
-			mutex.Unlock()
-
-			res.Message = "Vegitable '" + vegitable.Name + "' is added successfully!"
-			res.Ok = true
-			return nil
-		} else {
-			res.Message = "Invalid number of inputs for 'add vegitable' command!"
-			res.Ok = false
-			return nil
-		}
-	} else {
-		res.Message = "Unknown command format: 'add " + req.Command[0] + "'"
-		res.Ok = false
-		return nil
+	if !res.Ok {
+		return render.Failure(res.Code, res.Message)
 	}
+
+	s.out.Table(render.AuditEntries(res.Entries))
 	return nil
 }
 
-func (h *Handler) CupdateVegitable(req core.Request, res *core.Response) (err error) {
-	if req.Command[0] == "" {
-		err = errors.New("Command shoud be specified!")
+// rpcServer builds the RPC server of a connection with the
+// methods of V1 and the legacy Handler, so that changes
+// made through it are recorded with conn.
+func (s *Server) rpcServer(conn string) (server *rpc.Server, err error) {
+	v1 := *s.v1
+	v1.conn = conn
+
+	server = rpc.NewServer()
+
+	err = server.RegisterName("V1", &v1)
+	if err != nil {
 		return
 	}
 
-	if req.Command[0] == "price" {
-		if len(req.Command) == 3 {
-			for v := 0; v < len(vegitables.Vegitables); v++ {
-				if vegitables.Vegitables[v].Name == req.Command[1] {
-					mutex.Lock()
-					vegitables.Vegitables[v].PricePerKg = req.Command[2]
-					writeToDB()
-					mutex.Unlock()
-					res.Message = "Vegitable '" + vegitables.Vegitables[v].Name + "' is updated successfully!"
-					res.Ok = true
-					return nil
-				}
-			}
-			res.Message = "Vegitable '" + req.Command[1] + "' is not found!"
-			res.Ok = false
-			return nil
-		} else {
-			res.Message = "Invalid number of inputs for 'update price' command!"
-			res.Ok = false
-			return nil
-		}
-	} else if req.Command[0] == "stocks" {
-		if len(req.Command) == 3 {
-			for v := 0; v < len(vegitables.Vegitables); v++ {
-				if vegitables.Vegitables[v].Name == req.Command[1] {
-					mutex.Lock()
-					vegitables.Vegitables[v].RemainingKgs = req.Command[2]
-					writeToDB()
-					mutex.Unlock()
-					res.Message = "Vegitable '" + vegitables.Vegitables[v].Name + "' is updated successfully!"
-					res.Ok = true
-					return nil
-				}
-			}
-			res.Message = "Vegitable '" + req.Command[1] + "' is not found!"
-			res.Ok = false
-			return nil
-		} else {
-			res.Message = "Invalid number of inputs for 'update price' command!"
-			res.Ok = false
-			return nil
-		}
-	} else {
-		res.Message = "Unknown command format: 'add " + req.Command[0] + "'"
-		res.Ok = false
-		return nil
-	}
-	return nil
+	err = server.Register(&Handler{v1: &v1})
+	return
 }
 
 // Starts initializes the RPC server by first verifying
 // if all the necessary configuration has been set.
 //
-// It then publishes the receiver's methods (core.Handler)
-// in the default RPC server. By doing so, the `Handler`
-// public methods that satisfy the rpc interface become
-// available to clients connecting to this server.
+// Every connection gets its own RPC server publishing the
+// receivers' methods (V1 and the legacy Handler). By doing
+// so, their public methods that satisfy the rpc interface
+// become available to clients connecting to this server.
 //
-// With the receiver registered, it starts the server
-// such that new connections can be accepted.
+// With the receiver registered, it starts listening on
+// every port such that new connections can be accepted,
+// whatever transport they use.
 func (s *Server) StartServer() (err error) {
 	if s.Port <= 0 {
 		err = errors.New("port must be specified")
 		return
 	}
 
-	rpc.Register(&Handler{
-		Sleep: s.Sleep,
-	})
-
-	s.listener, err = net.Listen("tcp", ":"+strconv.Itoa(int(s.Port)))
-	if err != nil {
+	if s.Store == nil {
+		err = errors.New("store must be specified")
 		return
 	}
 
-	// Open our xmlFile
-	xmlFile, err := os.Open("db.xml")
+	err = s.listen()
 	if err != nil {
-		fmt.Println(err)
+		return
 	}
 
-	// defer the closing of our xmlFile so that we can parse it later on
-	defer xmlFile.Close()
-
-	// read our opened xmlFile as a byte array.
-	byteValue, _ := ioutil.ReadAll(xmlFile)
-
-	// unmarshaling  byteArray
-	xml.Unmarshal(byteValue, &vegitables)
-
 	go func() {
 		s.StartMenu()
 		s.Close()
 		os.Exit(0)
 	}()
 
-	if s.UseHttp {
-		rpc.HandleHTTP()
-		http.Serve(s.listener, nil)
-	} else if s.UseJson {
-		var conn net.Conn
+	return s.serve(s.listeners[0])
+}
+
+// listen starts listening on every port, serving all of
+// them but the first one, which is left to the caller.
+func (s *Server) listen() (err error) {
+	s.v1 = &V1{
+		Sleep: s.Sleep,
+		Store: s.Store,
+		mutex: &s.mutex,
+		auth:  s.Auth,
+		audit: s.Audit,
+		feed:  &s.feed,
+
+		// nothing is sent while a Watch waits, it answers
+		// well before the connection would be idle.
+		maxWait: s.IdleTimeout / 2,
+	}
+
+	// the receivers are checked once so a connection
+	// never fails to get its RPC server.
+	if _, err = s.rpcServer(""); err != nil {
+		return
+	}
+
+	s.netMutex.Lock()
+	if s.closed {
+		s.netMutex.Unlock()
+		return errClosed
+	}
+	s.done = make(chan struct{})
+	s.v1.done = s.done
+	s.netMutex.Unlock()
+
+	for _, port := range append([]uint{s.Port}, s.ExtraPorts...) {
+		var l net.Listener
 
-		for {
-			conn, err = s.listener.Accept()
-			if err != nil {
-				return
-			}
+		l, err = net.Listen("tcp", ":"+strconv.Itoa(int(port)))
+		if err != nil {
+			s.Close()
+			return
+		}
+
+		// with TLS every transport (gob, JSON-RPC and
+		// HTTP) runs on top of the encrypted
+		// connections.
+		if s.TLS != nil {
+			l = tls.NewListener(l, s.TLS)
+		}
 
-			jsonrpc.ServeConn(conn)
+		err = s.addListener(l)
+		if err != nil {
+			return
 		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(rpc.DefaultRPCPath, s.serveHTTP)
+	mux.HandleFunc("/watch", s.serveWatch)
+
+	s.netMutex.Lock()
+	if s.closed {
+		s.netMutex.Unlock()
+		return errClosed
+	}
+	s.http = newConnListener(s.listeners[0].Addr())
+	s.netMutex.Unlock()
+
+	go (&http.Server{Handler: mux, ConnContext: withConnName}).Serve(s.http)
 
-	} else {
-		rpc.Accept(s.listener)
+	for _, l := range s.listeners[1:] {
+		go s.serve(l)
 	}
 
 	return
 }
 
+// enter counts a goroutine serving a connection in
+// unless the server is closed. It calls serving.Done when
+// it returns.
+func (s *Server) enter() bool {
+	s.netMutex.Lock()
+	defer s.netMutex.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.serving.Add(1)
+	return true
+}
+
+func (s *Server) isClosed() bool {
+	s.netMutex.Lock()
+	defer s.netMutex.Unlock()
+
+	return s.closed
+}
+
+// drain waits for the goroutines serving connections to
+// return, no longer than drainTimeout.
+func (s *Server) drain() {
+	drained := make(chan struct{})
+	go func() {
+		s.serving.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		log.Println("closing the store with calls still in flight")
+	}
+}
+
+// addListener keeps l to be closed along with the server,
+// closing it right away when the server already is.
+func (s *Server) addListener(l net.Listener) error {
+	s.netMutex.Lock()
+	defer s.netMutex.Unlock()
+
+	if s.closed {
+		l.Close()
+		return errClosed
+	}
+
+	s.listeners = append(s.listeners, l)
+	return nil
+}
+
 func (s *Server) StartMenu() (err error) {
+	s.console = &Handler{v1: &V1{
+		Store:   s.Store,
+		mutex:   &s.mutex,
+		trusted: &operator,
+		audit:   s.Audit,
+		conn:    "console",
+		feed:    &s.feed,
+	}}
+	s.out = render.New(os.Stdout, s.Output)
+
+	name := menu.Arg{Name: "vegitable name", Complete: s.vegitableNames}
+
 	commandOptions := []menu.CommandOption{
-		menu.CommandOption{"show", "\n" +
-			"\tshow vegitable all\t: Shows all the vegitables\n" +
-			"\tshow vegitable <vegitable name>\t: Shows unit price and stocks of a given vegitable\n" +
-			"\tshow price <vegitable name>\t: Shows the unit price of a given vegitable\n" +
-			"\tshow stocks <vegitable name>\t: Shows the stocks of a given vegitable", showVegitable},
-		menu.CommandOption{"add", "\n" +
-			"\tadd vegitable <vegitable name> <unit price> <stocks(KG)>\t: Adds a new vegitable with a given unit price & a stock value in KG", addVegitable},
-		menu.CommandOption{"update", "\n" +
-			"\tupdate price <vegitable name> <unit price>\t: Updates the unit price of a given vegitable\n" +
-			"\tupdate stocks <vegitable name> <stocks(KG)>\t: Updates the stocks of a given vegitable", updateVegitable},
+		{Command: "show", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Shows unit price and stocks of a given vegitable", Args: []menu.Arg{name}, Function: prefixed(s.showVegitable, "vegitable"), Subcommands: []menu.CommandOption{
+				{Command: "all", Description: "Shows all the vegitables", Function: prefixed(s.showVegitable, "vegitable", "all")},
+			}},
+			{Command: "price", Description: "Shows the unit price of a given vegitable", Args: []menu.Arg{name}, Function: prefixed(s.showVegitable, "price")},
+			{Command: "stocks", Description: "Shows the stocks of a given vegitable", Args: []menu.Arg{name}, Function: prefixed(s.showVegitable, "stocks")},
+		}},
+		{Command: "add", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Adds a new vegitable with a given unit price & a stock value in KG", Args: []menu.Arg{name, {Name: "unit price", Type: menu.Decimal}, {Name: "stocks(KG)", Type: menu.Decimal}}, Function: prefixed(s.addVegitable, "vegitable")},
+		}},
+		{Command: "update", Subcommands: []menu.CommandOption{
+			{Command: "price", Description: "Updates the unit price of a given vegitable", Args: []menu.Arg{name, {Name: "unit price", Type: menu.Decimal}}, Function: prefixed(s.updateVegitable, "price")},
+			{Command: "stocks", Description: "Updates the stocks of a given vegitable", Args: []menu.Arg{name, {Name: "stocks(KG)", Type: menu.Decimal}}, Function: prefixed(s.updateVegitable, "stocks")},
+		}},
+		{Command: "delete", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Deletes a given vegitable", Args: []menu.Arg{name}, Function: prefixed(s.deleteVegitable, "vegitable")},
+		}},
+		{Command: "connections", Description: "Shows the open client connections", Function: s.showConnections},
+		{Command: "rename", Subcommands: []menu.CommandOption{
+			{Command: "vegitable", Description: "Renames a given vegitable keeping its unit price & stocks", Args: []menu.Arg{name, {Name: "new name"}}, Function: prefixed(s.renameVegitable, "vegitable")},
+		}},
+		{Command: "history", Description: "Shows who changed a given vegitable, when and how", Args: []menu.Arg{name}, Function: s.showHistory},
 	}
 
 	menuOptions := menu.NewMenuOptions("'menu' for help > ", 500)
+	menuOptions.HistoryFile = s.HistoryFile
+	menuOptions.Renderer = s.out
 
 	menu := menu.NewMenu(commandOptions, menuOptions)
 	menu.Start()
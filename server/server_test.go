@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/client"
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/store"
+)
+
+// A signal may close the server while it is starting,
+// which must neither race nor leave a port open.
+func TestCloseWhileStarting(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		s := &Server{Store: store.NewMemory(), ExtraPorts: []uint{0, 0}}
+
+		closed := make(chan struct{})
+		go func() {
+			s.Close()
+			close(closed)
+		}()
+
+		err := s.listen()
+		<-closed
+
+		if err == nil {
+			go s.serve(s.listeners[0])
+		} else if err != errClosed {
+			t.Fatal(err)
+		}
+
+		s.Close()
+
+		for _, l := range s.listeners {
+			if _, err := l.Accept(); err == nil {
+				t.Fatalf("listener %s is still open", l.Addr())
+			}
+		}
+	}
+}
+
+// A client sending nothing is closed by the idle timeout
+// before its transport is even known.
+func TestSilentClientIsClosed(t *testing.T) {
+	port := start(t, &Server{IdleTimeout: 200 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(int(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("got %v, want the server to close the connection", err)
+	}
+}
+
+// A long poll answers before the idle timeout would close
+// its connection.
+func TestWatchWithinIdleTimeout(t *testing.T) {
+	port := start(t, &Server{IdleTimeout: 400 * time.Millisecond})
+
+	c := &client.Client{Host: "127.0.0.1", Port: port, Retries: -1}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := c.Watch(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Now()
+	if _, err := c.Watch(ctx, res.Epoch, res.Next, time.Minute); err != nil {
+		t.Fatalf("the long poll failed: %v", err)
+	}
+	if waited := time.Since(started); waited >= 400*time.Millisecond {
+		t.Fatalf("the long poll waited %s, longer than the idle timeout", waited)
+	}
+}
+
+// Close ends the connections still open and the calls in
+// flight on them before the store is closed, so nothing
+// reaches a closed store.
+func TestCloseEndsOpenConnections(t *testing.T) {
+	s := &Server{}
+	port := start(t, s)
+
+	c := &client.Client{Host: "127.0.0.1", Port: port, Retries: -1}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := c.Watch(ctx, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watched := make(chan error, 1)
+	go func() {
+		_, err := c.Watch(ctx, res.Epoch, res.Next, time.Minute)
+		watched <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	started := time.Now()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(started); waited >= drainTimeout {
+		t.Fatalf("Close waited %s for the long poll", waited)
+	}
+	<-watched
+
+	if _, err := c.AddVegitable(ctx, core.Vegitable{Name: "Carrot", PricePerKg: 9000}); err == nil {
+		t.Fatal("a call after Close succeeded")
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/client"
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/internal/testcert"
+	"github.com/dimalkavindu/go-rpc/store"
+)
+
+// start serves a store holding Beans on a free port of
+// the loopback, without the console menu.
+func start(t *testing.T, s *Server) uint {
+	t.Helper()
+
+	s.Store = store.NewMemory(core.Vegitable{Name: "Beans", PricePerKg: 17500, RemainingKgs: 10100})
+
+	if err := s.listen(); err != nil {
+		t.Fatal(err)
+	}
+	go s.serve(s.listeners[0])
+	t.Cleanup(func() { s.Close() })
+
+	return uint(s.listeners[0].Addr().(*net.TCPAddr).Port)
+}
+
+// transports are the ways a client can talk to the server,
+// each configures a client to use one.
+var transports = []struct {
+	name string
+	use  func(c *client.Client)
+}{
+	{"gob", func(c *client.Client) {}},
+	{"json", func(c *client.Client) { c.UseJson = true }},
+	{"http", func(c *client.Client) { c.UseHttp = true }},
+}
+
+// list connects to the server on port and lists the
+// vegitables.
+func list(port uint, config *tls.Config, use func(c *client.Client)) ([]core.Vegitable, error) {
+	c := &client.Client{Host: "127.0.0.1", Port: port, TLS: config, Retries: -1}
+	use(c)
+
+	if err := c.Init(); err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.ListVegitables(ctx)
+}
+
+func TestTLSRoundTrip(t *testing.T) {
+	files := testcert.Generate(t)
+
+	config, err := (core.TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey}).ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := start(t, &Server{TLS: config})
+
+	clientConfig, err := (core.TLSFiles{CAFile: files.CA}).ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transport := range transports {
+		t.Run(transport.name, func(t *testing.T) {
+			vegitables, err := list(port, clientConfig, transport.use)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(vegitables) != 1 || vegitables[0].Name != "Beans" || vegitables[0].PricePerKg != 17500 {
+				t.Fatalf("got %+v, want Beans", vegitables)
+			}
+		})
+	}
+
+	// a client that does not speak TLS gets nowhere
+	if _, err := list(port, nil, func(c *client.Client) {}); err == nil {
+		t.Error("a plain client was served by a TLS server")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	files := testcert.Generate(t)
+
+	config, err := (core.TLSFiles{CertFile: files.ServerCert, KeyFile: files.ServerKey, CAFile: files.CA, VerifyClient: true}).ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := start(t, &Server{TLS: config})
+
+	certified, err := (core.TLSFiles{CertFile: files.ClientCert, KeyFile: files.ClientKey, CAFile: files.CA}).ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, err := (core.TLSFiles{CAFile: files.CA}).ClientConfig("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transport := range transports {
+		t.Run(transport.name, func(t *testing.T) {
+			if _, err := list(port, certified, transport.use); err != nil {
+				t.Fatalf("a client with a certificate failed: %v", err)
+			}
+
+			if _, err := list(port, anonymous, transport.use); err == nil {
+				t.Fatal("a client without a certificate was served")
+			}
+		})
+	}
+}
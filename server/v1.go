@@ -0,0 +1,751 @@
+package server
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/store"
+)
+
+// V1 holds the methods of the typed RPC API. It is
+// registered under the "V1" name, so clients call e.g.
+// "V1.GetPrice" with a core.GetPriceRequest.
+//
+// Every other way of reaching the inventory (the legacy
+// `Handler` and the server menu) goes through these
+// methods.
+type V1 struct {
+	// Sleep adds a little sleep between to the
+	// method execution to simulate a time-consuming
+	// operation.
+	Sleep time.Duration
+
+	// Store is the inventory backend the methods
+	// read from and write to.
+	Store store.Store
+
+	// mutex serializes the read-modify-write
+	// sequences of the mutating methods.
+	mutex *sync.Mutex
+
+	// auth authenticates the sessions of the
+	// requests. Without it every caller is an
+	// anonymous admin.
+	auth *Auth
+
+	// trusted, when set, makes every call on behalf
+	// of that identity without any session. The
+	// server menu runs as the operator.
+	trusted *identity
+
+	// audit records every change of the inventory,
+	// conn tells the entries which connection the
+	// calls arrived on.
+	audit *AuditLog
+	conn  string
+
+	// feed publishes every change of the inventory
+	// to the watchers.
+	feed *feed
+
+	// maxWait, when positive, caps the wait of Watch
+	// further so that the idle timeout never closes a
+	// connection in the middle of a long poll.
+	maxWait time.Duration
+
+	// done is closed along with the server, ending the
+	// Watch calls in flight.
+	done <-chan struct{}
+}
+
+// succeeded and failed build the Status of a response.
+func succeeded(message string) core.Status {
+	return core.Status{Ok: true, Code: core.CodeOK, Message: message}
+}
+
+func failed(code core.ErrorCode, message string) core.Status {
+	return core.Status{Ok: false, Code: code, Message: message}
+}
+
+func notFound(name string) core.Status {
+	return failed(core.CodeNotFound, "Vegitable '"+name+"' is not found!")
+}
+
+// authorize checks that the session of a request may call
+// method. On failure status explains why.
+func (h *V1) authorize(credentials core.Credentials, method string) (who identity, status core.Status, ok bool) {
+	if h.trusted != nil {
+		return *h.trusted, status, true
+	}
+
+	if h.auth == nil {
+		return anonymous, status, true
+	}
+
+	who, ok = h.auth.identify(credentials.Session)
+	if !ok {
+		status = failed(core.CodeUnauthorized, "Please login first!")
+		return
+	}
+
+	if !who.Role.Can(method) {
+		status = failed(core.CodeUnauthorized, "The '"+string(who.Role)+"' role is not allowed to do that!")
+		return who, status, false
+	}
+
+	return
+}
+
+// Login exchanges an API token for a session to be sent
+// along with the following requests.
+func (h *V1) Login(req core.LoginRequest, res *core.SessionResponse) (err error) {
+	if h.auth == nil {
+		res.Status = succeeded("Authentication is disabled, you are an anonymous admin!")
+		res.Name = anonymous.Name
+		res.Role = anonymous.Role
+		return
+	}
+
+	session, who, ok := h.auth.login(req.Token)
+	if !ok {
+		res.Status = failed(core.CodeUnauthorized, "Invalid token!")
+		return
+	}
+
+	res.Status = succeeded("Logged in as '" + who.Name + "' (" + string(who.Role) + ")!")
+	res.Session = session
+	res.Name = who.Name
+	res.Role = who.Role
+	return
+}
+
+// WhoAmI describes the session of the request.
+func (h *V1) WhoAmI(req core.WhoAmIRequest, res *core.SessionResponse) (err error) {
+	who := anonymous
+	if h.auth != nil {
+		var ok bool
+
+		who, ok = h.auth.identify(req.Session)
+		if !ok {
+			res.Status = failed(core.CodeUnauthorized, "Please login first!")
+			return
+		}
+	}
+
+	res.Status = succeeded("You are '" + who.Name + "' (" + string(who.Role) + ")!")
+	res.Session = req.Session
+	res.Name = who.Name
+	res.Role = who.Role
+	return
+}
+
+// record publishes a change to the watchers and adds an
+// entry for it to the audit log. The change is already
+// persisted, so a failure to record it is only logged.
+//
+// It must be called under the mutex so that the events
+// are in the same order as the changes.
+func (h *V1) record(who identity, action string, old, new *core.Vegitable) {
+	if h.feed != nil {
+		h.feed.publish(action, old, new)
+	}
+
+	if h.audit == nil {
+		return
+	}
+
+	entry := core.AuditEntry{
+		Time:       time.Now(),
+		Actor:      who.Name,
+		Role:       who.Role,
+		Connection: h.conn,
+		Action:     action,
+		Old:        old,
+		New:        new,
+	}
+	if old != nil {
+		entry.Name = old.Name
+	} else {
+		entry.Name = new.Name
+	}
+
+	if err := h.audit.Record(entry); err != nil {
+		log.Println("cannot record audit entry:", err)
+	}
+}
+
+// put stores the vegitable and updates it with the version
+// it was given by the store.
+func (h *V1) put(v *core.Vegitable) error {
+	mutations := []store.Mutation{{Op: store.OpPut, Vegitable: *v}}
+
+	err := h.Store.Apply(mutations...)
+	if err != nil {
+		return err
+	}
+
+	*v = mutations[0].Vegitable
+	return nil
+}
+
+func (h *V1) sleep() {
+	if h.Sleep != 0 {
+		time.Sleep(h.Sleep)
+	}
+}
+
+func (h *V1) GetVegitable(req core.GetVegitableRequest, res *core.VegitableResponse) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "GetVegitable"); !ok {
+		return
+	}
+
+	h.sleep()
+
+	res.Vegitable, err = h.Store.Get(req.Name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(req.Name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+func (h *V1) ListVegitables(req core.ListVegitablesRequest, res *core.VegitablesResponse) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "ListVegitables"); !ok {
+		return
+	}
+
+	h.sleep()
+
+	res.Vegitables, err = h.Store.List()
+	if err != nil {
+		return
+	}
+
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+func (h *V1) GetPrice(req core.GetPriceRequest, res *core.PriceResponse) (err error) {
+	var v core.VegitableResponse
+
+	err = h.GetVegitable(core.GetVegitableRequest{Credentials: req.Credentials, Name: req.Name}, &v)
+	res.Status = v.Status
+	res.Name = v.Vegitable.Name
+	res.PricePerKg = v.Vegitable.PricePerKg
+	return
+}
+
+func (h *V1) GetStocks(req core.GetStocksRequest, res *core.StocksResponse) (err error) {
+	var v core.VegitableResponse
+
+	err = h.GetVegitable(core.GetVegitableRequest{Credentials: req.Credentials, Name: req.Name}, &v)
+	res.Status = v.Status
+	res.Name = v.Vegitable.Name
+	res.RemainingKgs = v.Vegitable.RemainingKgs
+	return
+}
+
+func (h *V1) AddVegitable(req core.AddVegitableRequest, res *core.VegitableResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "AddVegitable")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	h.sleep()
+
+	vegitable := req.Vegitable
+	vegitable.Version = 0
+	if vegitable.Name == "" {
+		res.Status = failed(core.CodeInvalidArgument, "Vegitable name should be specified!")
+		return
+	}
+	if vegitable.PricePerKg < 0 {
+		res.Status = failed(core.CodeInvalidArgument, "Invalid unit price '"+vegitable.PricePerKg.String()+"'!")
+		return
+	}
+	if vegitable.RemainingKgs < 0 {
+		res.Status = failed(core.CodeInvalidArgument, "Invalid stocks(KG) '"+vegitable.RemainingKgs.String()+"'!")
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	_, err = h.Store.Get(vegitable.Name)
+	if err == nil {
+		res.Status = failed(core.CodeAlreadyExists, "Cannot add the vegitable! Vegitable '"+vegitable.Name+"' already exists!")
+		return
+	}
+	if err != store.ErrNotFound {
+		return
+	}
+
+	err = h.put(&vegitable)
+	if err != nil {
+		return
+	}
+
+	h.record(who, "add", nil, &vegitable)
+
+	res.Status = succeeded("Vegitable '" + vegitable.Name + "' is added successfully!")
+	res.Vegitable = vegitable
+	return
+}
+
+func (h *V1) UpdatePrice(req core.UpdatePriceRequest, res *core.VegitableResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "UpdatePrice")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	if req.PricePerKg < 0 {
+		res.Status = failed(core.CodeInvalidArgument, "Invalid unit price '"+req.PricePerKg.String()+"'!")
+		return
+	}
+
+	return h.update(who, "update price", req.Name, req.Version, res, func(v *core.Vegitable) {
+		v.PricePerKg = req.PricePerKg
+	})
+}
+
+func (h *V1) UpdateStocks(req core.UpdateStocksRequest, res *core.VegitableResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "UpdateStocks")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	if req.RemainingKgs < 0 {
+		res.Status = failed(core.CodeInvalidArgument, "Invalid stocks(KG) '"+req.RemainingKgs.String()+"'!")
+		return
+	}
+
+	return h.update(who, "update stocks", req.Name, req.Version, res, func(v *core.Vegitable) {
+		v.RemainingKgs = req.RemainingKgs
+	})
+}
+
+// update applies change to the named vegitable under the
+// mutex, persists the result and records it as action.
+//
+// Unless version is zero the vegitable must still be at
+// that version, otherwise the change was based on a stale
+// read and is rejected as a conflict.
+func (h *V1) update(who identity, action string, name string, version uint64, res *core.VegitableResponse, change func(v *core.Vegitable)) (err error) {
+	h.sleep()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	vegitable, err := h.Store.Get(name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if stale(vegitable, version, res) {
+		return
+	}
+
+	old := vegitable
+	change(&vegitable)
+
+	err = h.put(&vegitable)
+	if err != nil {
+		return
+	}
+
+	h.record(who, action, &old, &vegitable)
+
+	res.Status = succeeded("Vegitable '" + vegitable.Name + "' is updated successfully!")
+	res.Vegitable = vegitable
+	return
+}
+
+// stale rejects a change based on another version of the
+// vegitable than the one it is at, unless version is zero.
+// The response of a conflict carries the current one.
+func stale(vegitable core.Vegitable, version uint64, res *core.VegitableResponse) bool {
+	if version == 0 || version == vegitable.Version {
+		return false
+	}
+
+	res.Status = failed(core.CodeConflict, "Vegitable '"+vegitable.Name+"' was changed in the meantime! It is at version "+
+		strconv.FormatUint(vegitable.Version, 10)+", not "+strconv.FormatUint(version, 10)+".")
+	res.Vegitable = vegitable
+	return true
+}
+
+// DeleteVegitable removes a vegitable, unless req.Version
+// is given and it is at another one by now.
+func (h *V1) DeleteVegitable(req core.DeleteVegitableRequest, res *core.VegitableResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "DeleteVegitable")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	h.sleep()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	res.Vegitable, err = h.Store.Get(req.Name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(req.Name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if stale(res.Vegitable, req.Version, res) {
+		return
+	}
+
+	err = h.Store.Delete(req.Name)
+	if err != nil {
+		return
+	}
+
+	h.record(who, "delete", &res.Vegitable, nil)
+
+	res.Status = succeeded("Vegitable '" + req.Name + "' is deleted successfully!")
+	return
+}
+
+// RenameVegitable gives a vegitable a new name, keeping
+// its price and stocks, with a single atomic store update.
+// Like DeleteVegitable it checks req.Version.
+func (h *V1) RenameVegitable(req core.RenameVegitableRequest, res *core.VegitableResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "RenameVegitable")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	h.sleep()
+
+	if req.NewName == "" {
+		res.Status = failed(core.CodeInvalidArgument, "The new vegitable name should be specified!")
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	vegitable, err := h.Store.Get(req.Name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(req.Name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	if stale(vegitable, req.Version, res) {
+		return
+	}
+
+	if req.Name == req.NewName {
+		res.Status = failed(core.CodeAlreadyExists, "Vegitable '"+req.Name+"' already has that name!")
+		return
+	}
+
+	_, err = h.Store.Get(req.NewName)
+	if err == nil {
+		res.Status = failed(core.CodeAlreadyExists, "Cannot rename the vegitable! Vegitable '"+req.NewName+"' already exists!")
+		return
+	}
+	if err != store.ErrNotFound {
+		return
+	}
+
+	old := vegitable
+	vegitable.Name = req.NewName
+
+	mutations := []store.Mutation{
+		{Op: store.OpDelete, Name: req.Name},
+		{Op: store.OpPut, Vegitable: vegitable},
+	}
+
+	err = h.Store.Apply(mutations...)
+	if err != nil {
+		return
+	}
+	vegitable = mutations[1].Vegitable
+
+	h.record(who, "rename", &old, &vegitable)
+
+	res.Status = succeeded("Vegitable '" + req.Name + "' is renamed to '" + req.NewName + "' successfully!")
+	res.Vegitable = vegitable
+	return
+}
+
+// History returns the audit entries of a vegitable,
+// oldest first.
+func (h *V1) History(req core.HistoryRequest, res *core.HistoryResponse) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "History"); !ok {
+		return
+	}
+
+	if h.audit == nil {
+		res.Status = failed(core.CodeNotFound, "The server keeps no audit log!")
+		return
+	}
+
+	res.Entries, err = h.audit.History(req.Name)
+	if err != nil {
+		return
+	}
+
+	if len(res.Entries) == 0 {
+		res.Status = failed(core.CodeNotFound, "No history is recorded for vegitable '"+req.Name+"'!")
+		return
+	}
+
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+// PriceAt returns the unit price and stocks a vegitable
+// had at the requested time, which is not found when the
+// vegitable did not exist back then. A revision counts
+// from its very time on.
+//
+// The price history is kept by name: a rename ends it as
+// a deletion and starts the one of the new name, unlike
+// the audit History, which follows the renames.
+func (h *V1) PriceAt(req core.PriceAtRequest, res *core.RevisionResponse) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "PriceAt"); !ok {
+		return
+	}
+
+	h.sleep()
+
+	revisions, err := h.Store.History(req.Name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(req.Name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	res.Name = req.Name
+
+	// the last revision made at or before the time.
+	i := sort.Search(len(revisions), func(i int) bool {
+		return revisions[i].Time.After(req.At)
+	})
+	if i == 0 || revisions[i-1].Deleted {
+		res.Status = failed(core.CodeNotFound, "Vegitable '"+req.Name+"' did not exist at "+req.At.Format(time.RFC3339)+"!")
+		return
+	}
+
+	res.Revision = revisions[i-1]
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+// PriceHistory returns every revision of the unit price
+// and stocks of a vegitable, oldest first, under its
+// current name only (see PriceAt).
+func (h *V1) PriceHistory(req core.PriceHistoryRequest, res *core.RevisionsResponse) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "PriceHistory"); !ok {
+		return
+	}
+
+	h.sleep()
+
+	res.Revisions, err = h.Store.History(req.Name)
+	if err == store.ErrNotFound {
+		res.Status = notFound(req.Name)
+		return nil
+	}
+	if err != nil {
+		return
+	}
+
+	res.Name = req.Name
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+// Purchase sells a quantity of a single vegitable.
+func (h *V1) Purchase(req core.PurchaseRequest, res *core.ReceiptResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "Purchase")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	h.sleep()
+
+	receipt, lineErrors, err := h.sell(who, core.OrderLine{Name: req.Name, Kgs: req.Kgs})
+	if err != nil {
+		return
+	}
+
+	if len(lineErrors) > 0 {
+		res.Status = failed(lineErrors[0].Code, lineErrors[0].Reason)
+		return
+	}
+
+	res.Status = succeeded("Purchased " + req.Kgs.String() + " KG of '" + req.Name + "' successfully!")
+	res.Receipt = receipt
+	return
+}
+
+// PlaceOrder buys every line of the order in a single
+// atomic store update. If any line cannot be fulfilled
+// nothing is bought and the reason for every failing line
+// is reported in LineErrors, the response carries the
+// code of the first one.
+func (h *V1) PlaceOrder(req core.OrderRequest, res *core.ReceiptResponse) (err error) {
+	who, status, ok := h.authorize(req.Credentials, "PlaceOrder")
+	if !ok {
+		res.Status = status
+		return
+	}
+
+	h.sleep()
+
+	if len(req.Lines) == 0 {
+		res.Status = failed(core.CodeInvalidArgument, "The order has no lines!")
+		return
+	}
+
+	receipt, lineErrors, err := h.sell(who, req.Lines...)
+	if err != nil {
+		return
+	}
+
+	if len(lineErrors) > 0 {
+		res.Status = failed(lineErrors[0].Code, "The order is rejected! "+strconv.Itoa(len(lineErrors))+" line(s) cannot be fulfilled.")
+		res.LineErrors = lineErrors
+		return
+	}
+
+	res.Status = succeeded("The order of " + strconv.Itoa(len(receipt.Lines)) + " line(s) is placed successfully!")
+	res.Receipt = receipt
+	return
+}
+
+// sell validates every line against the current stocks
+// and, only if all of them can be fulfilled, decrements
+// the stocks with a single store update.
+//
+// The stocks are checked and decremented under the mutex
+// so concurrent sales can never oversell. A vegitable
+// appearing in several lines must have enough stock for
+// all of them. Each vegitable sold is recorded once.
+func (h *V1) sell(who identity, lines ...core.OrderLine) (receipt core.Receipt, lineErrors []core.OrderLineError, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var (
+		vegitables = make(map[string]*core.Vegitable)
+		olds       = make(map[string]core.Vegitable)
+		mutations  []store.Mutation
+	)
+
+	for i, line := range lines {
+		reject := func(code core.ErrorCode, reason string) {
+			lineErrors = append(lineErrors, core.OrderLineError{Line: i, Name: line.Name, Code: code, Reason: reason})
+		}
+
+		if line.Kgs <= 0 {
+			reject(core.CodeInvalidArgument, "Invalid quantity(KG) '"+line.Kgs.String()+"'!")
+			continue
+		}
+
+		vegitable, ok := vegitables[line.Name]
+		if !ok {
+			var v core.Vegitable
+
+			v, err = h.Store.Get(line.Name)
+			if err == store.ErrNotFound {
+				err = nil
+				reject(core.CodeNotFound, "Vegitable '"+line.Name+"' is not found!")
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			vegitable = &v
+			vegitables[line.Name] = vegitable
+			olds[line.Name] = v
+		}
+
+		if vegitable.RemainingKgs < line.Kgs {
+			reject(core.CodeInsufficientStock, "Insufficient stocks! Only "+vegitable.RemainingKgs.String()+
+				" KG of '"+vegitable.Name+"' is available!")
+			continue
+		}
+
+		amount, overflow := vegitable.PricePerKg.Times(line.Kgs)
+		if overflow == nil {
+			receipt.Total, overflow = receipt.Total.Plus(amount)
+		}
+		if overflow != nil {
+			reject(core.CodeInvalidArgument, "The amount of "+line.Kgs.String()+" KG of '"+vegitable.Name+"' is too large!")
+			continue
+		}
+
+		vegitable.RemainingKgs -= line.Kgs
+
+		receipt.Lines = append(receipt.Lines, core.ReceiptLine{
+			Name:       vegitable.Name,
+			Kgs:        line.Kgs,
+			PricePerKg: vegitable.PricePerKg,
+			Amount:     amount,
+		})
+	}
+
+	if len(lineErrors) > 0 {
+		receipt = core.Receipt{}
+		return
+	}
+
+	var sold []string
+	for _, line := range receipt.Lines {
+		if v, ok := vegitables[line.Name]; ok {
+			mutations = append(mutations, store.Mutation{Op: store.OpPut, Vegitable: *v})
+			sold = append(sold, line.Name)
+			delete(vegitables, line.Name)
+		}
+	}
+
+	err = h.Store.Apply(mutations...)
+	if err != nil {
+		return
+	}
+
+	for i, name := range sold {
+		old := olds[name]
+		h.record(who, "sell", &old, &mutations[i].Vegitable)
+	}
+
+	return
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+	"github.com/dimalkavindu/go-rpc/store"
+)
+
+// newV1 serves a store holding Beans at version 1 without
+// authentication.
+func newV1(t *testing.T) *V1 {
+	t.Helper()
+
+	s := store.NewMemory()
+	if err := s.Put(core.Vegitable{Name: "Beans", PricePerKg: 17500, RemainingKgs: 10100}); err != nil {
+		t.Fatal(err)
+	}
+
+	return &V1{Store: s, mutex: &sync.Mutex{}, feed: &feed{}}
+}
+
+// Every change based on a version the vegitable is no
+// longer at is rejected, leaving the vegitable alone.
+func TestStaleVersionConflicts(t *testing.T) {
+	changes := []struct {
+		name   string
+		change func(h *V1, version uint64, res *core.VegitableResponse) error
+	}{
+		{"update price", func(h *V1, version uint64, res *core.VegitableResponse) error {
+			return h.UpdatePrice(core.UpdatePriceRequest{Name: "Beans", PricePerKg: 18000, Version: version}, res)
+		}},
+		{"update stocks", func(h *V1, version uint64, res *core.VegitableResponse) error {
+			return h.UpdateStocks(core.UpdateStocksRequest{Name: "Beans", RemainingKgs: 5000, Version: version}, res)
+		}},
+		{"delete", func(h *V1, version uint64, res *core.VegitableResponse) error {
+			return h.DeleteVegitable(core.DeleteVegitableRequest{Name: "Beans", Version: version}, res)
+		}},
+		{"rename", func(h *V1, version uint64, res *core.VegitableResponse) error {
+			return h.RenameVegitable(core.RenameVegitableRequest{Name: "Beans", NewName: "Green Beans", Version: version}, res)
+		}},
+	}
+
+	for _, change := range changes {
+		t.Run(change.name, func(t *testing.T) {
+			h := newV1(t)
+
+			var res core.VegitableResponse
+			if err := change.change(h, 2, &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Code != core.CodeConflict || res.Vegitable.Version != 1 {
+				t.Fatalf("got %v at version %d, want a conflict with version 1", res.Code, res.Vegitable.Version)
+			}
+
+			v, err := h.Store.Get("Beans")
+			if err != nil || v.Version != 1 || v.PricePerKg != 17500 || v.RemainingKgs != 10100 {
+				t.Fatalf("Beans changed after a conflict: %+v, %v", v, err)
+			}
+
+			res = core.VegitableResponse{}
+			if err := change.change(h, 1, &res); err != nil {
+				t.Fatal(err)
+			}
+			if !res.Ok {
+				t.Fatalf("the change at the current version failed: %s", res.Message)
+			}
+		})
+	}
+}
+
+// order places an order of lines with the given names and
+// quantities.
+func order(t *testing.T, h *V1, lines ...core.OrderLine) core.ReceiptResponse {
+	t.Helper()
+
+	var res core.ReceiptResponse
+	if err := h.PlaceOrder(core.OrderRequest{Lines: lines}, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+// stocks returns the stocks of every vegitable by name.
+func stocks(t *testing.T, h *V1) map[string]core.Weight {
+	t.Helper()
+
+	vegitables, err := h.Store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stocks := make(map[string]core.Weight)
+	for _, v := range vegitables {
+		stocks[v.Name] = v.RemainingKgs
+	}
+
+	return stocks
+}
+
+// A single line that cannot be fulfilled rejects the whole
+// order, no stocks are taken.
+func TestRejectedOrderLeavesStocks(t *testing.T) {
+	h := newV1(t)
+	if err := h.Store.Put(core.Vegitable{Name: "Carrot", PricePerKg: 9000, RemainingKgs: 5000}); err != nil {
+		t.Fatal(err)
+	}
+
+	res := order(t, h,
+		core.OrderLine{Name: "Beans", Kgs: 1000},
+		core.OrderLine{Name: "Carrot", Kgs: 6000},
+		core.OrderLine{Name: "Leeks", Kgs: 1000},
+	)
+
+	if res.Ok || res.Code != core.CodeInsufficientStock || len(res.Receipt.Lines) != 0 {
+		t.Fatalf("got %v (%s) with %d receipt lines, want the order rejected", res.Code, res.Message, len(res.Receipt.Lines))
+	}
+	if len(res.LineErrors) != 2 || res.LineErrors[0].Line != 1 || res.LineErrors[1].Line != 2 || res.LineErrors[1].Code != core.CodeNotFound {
+		t.Fatalf("got %+v, want lines 2 and 3 explained", res.LineErrors)
+	}
+
+	if got := stocks(t, h); got["Beans"] != 10100 || got["Carrot"] != 5000 {
+		t.Fatalf("stocks changed after a rejected order: %v", got)
+	}
+}
+
+// The lines of the same vegitable take their stocks
+// together.
+func TestOrderSumsDuplicateLines(t *testing.T) {
+	h := newV1(t)
+
+	res := order(t, h, core.OrderLine{Name: "Beans", Kgs: 6000}, core.OrderLine{Name: "Beans", Kgs: 5000})
+	if res.Ok || len(res.LineErrors) != 1 || res.LineErrors[0].Line != 1 {
+		t.Fatalf("got %v (%s) %+v, want the second line short of stocks", res.Code, res.Message, res.LineErrors)
+	}
+	if got := stocks(t, h)["Beans"]; got != 10100 {
+		t.Fatalf("Beans at %s KG after a rejected order", got)
+	}
+
+	res = order(t, h, core.OrderLine{Name: "Beans", Kgs: 6000}, core.OrderLine{Name: "Beans", Kgs: 4000})
+	if !res.Ok || res.Receipt.Total != 175000 {
+		t.Fatalf("got %v (%s) totalling %s, want 175.00 a kg for 10 KG", res.Code, res.Message, res.Receipt.Total)
+	}
+	if got := stocks(t, h)["Beans"]; got != 100 {
+		t.Fatalf("Beans at %s KG, want 0.100", got)
+	}
+}
+
+// An amount too large for Money rejects the order rather
+// than wrapping around.
+func TestOrderOverflowIsRejected(t *testing.T) {
+	h := newV1(t)
+	if err := h.Store.Put(core.Vegitable{Name: "Saffron", PricePerKg: math.MaxInt64/2 + 1, RemainingKgs: math.MaxInt64}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := [][]core.OrderLine{
+		{{Name: "Saffron", Kgs: math.MaxInt64}},
+		{{Name: "Saffron", Kgs: 1000}, {Name: "Saffron", Kgs: 1000}},
+	}
+
+	for _, lines := range tests {
+		res := order(t, h, lines...)
+		if res.Ok || res.Code != core.CodeInvalidArgument || len(res.LineErrors) != 1 {
+			t.Errorf("%v: got %v (%s) %+v, want the order rejected as too large", lines, res.Code, res.Message, res.LineErrors)
+		}
+	}
+
+	if got := stocks(t, h)["Saffron"]; got != math.MaxInt64 {
+		t.Fatalf("Saffron at %s KG after rejected orders", got)
+	}
+}
+
+// A revision counts from its very time on, up to the next
+// one. Nothing is found before the first one or after a
+// deletion.
+func TestPriceAt(t *testing.T) {
+	h := newV1(t)
+
+	first := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	second, deleted := first.Add(time.Hour), first.Add(2*time.Hour)
+
+	err := h.Store.Apply(
+		store.Mutation{Op: store.OpPut, Vegitable: core.Vegitable{Name: "Leeks", PricePerKg: 30000, RemainingKgs: 2000}, Time: first},
+		store.Mutation{Op: store.OpPut, Vegitable: core.Vegitable{Name: "Leeks", PricePerKg: 32000, RemainingKgs: 2000}, Time: second},
+		store.Mutation{Op: store.OpDelete, Name: "Leeks", Time: deleted},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want core.Money // 0 for not found
+	}{
+		{first.Add(-time.Nanosecond), 0},
+		{first, 30000},
+		{second.Add(-time.Nanosecond), 30000},
+		{second, 32000},
+		{deleted.Add(-time.Nanosecond), 32000},
+		{deleted, 0},
+		{deleted.Add(time.Hour), 0},
+	}
+
+	for _, test := range tests {
+		var res core.RevisionResponse
+		if err := h.PriceAt(core.PriceAtRequest{Name: "Leeks", At: test.at}, &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if test.want == 0 {
+			if res.Code != core.CodeNotFound {
+				t.Errorf("at %s: got %v at %s, want not found", test.at, res.Code, res.Revision.PricePerKg)
+			}
+			continue
+		}
+
+		if !res.Ok || res.Revision.PricePerKg != test.want {
+			t.Errorf("at %s: got %v at %s, want %s", test.at, res.Code, res.Revision.PricePerKg, test.want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+const (
+	// DefaultWatchWait is how long Watch waits for an
+	// event when the request does not say.
+	DefaultWatchWait = 25 * time.Second
+
+	// MaxWatchWait caps the wait of a single Watch.
+	MaxWatchWait = time.Minute
+
+	// watchBacklog is the number of past events kept for
+	// watchers catching up, older ones need a reset.
+	watchBacklog = 1024
+)
+
+// feed numbers the changes of the inventory and keeps the
+// latest of them for watchers. The zero value is ready to
+// use.
+type feed struct {
+	mutex  sync.Mutex
+	epoch  int64
+	seq    uint64
+	events []core.Event
+
+	// wake is closed (and replaced) whenever an event
+	// is published.
+	wake chan struct{}
+}
+
+// start picks the epoch of the feed when it is first
+// used. The caller must hold the mutex.
+func (f *feed) start() {
+	if f.epoch == 0 {
+		f.epoch = time.Now().UnixNano()
+	}
+}
+
+func (f *feed) publish(action string, old, new *core.Vegitable) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.start()
+
+	f.seq++
+	f.events = append(f.events, core.Event{
+		Seq:    f.seq,
+		Time:   time.Now(),
+		Action: action,
+		Old:    old,
+		New:    new,
+	})
+	if len(f.events) > watchBacklog {
+		f.events = append([]core.Event(nil), f.events[len(f.events)-watchBacklog:]...)
+	}
+
+	if f.wake != nil {
+		close(f.wake)
+		f.wake = nil
+	}
+}
+
+// from returns the events from seq (of the given epoch)
+// on and the sequence number following them. Unless ok the
+// events are no longer (or never were) known and the
+// watcher has to start over. Without events wake tells
+// when there are new ones.
+func (f *feed) from(epoch int64, seq uint64) (events []core.Event, now int64, next uint64, ok bool, wake <-chan struct{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.start()
+
+	now = f.epoch
+	next = f.seq + 1
+	if epoch != f.epoch || seq == 0 || seq > next {
+		return
+	}
+
+	oldest := next
+	if len(f.events) > 0 {
+		oldest = f.events[0].Seq
+	}
+	if seq < oldest {
+		return
+	}
+
+	ok = true
+	events = append(events, f.events[len(f.events)-int(next-seq):]...)
+
+	if len(events) == 0 {
+		if f.wake == nil {
+			f.wake = make(chan struct{})
+		}
+		wake = f.wake
+	}
+
+	return
+}
+
+// Watch answers with the changes of the inventory from
+// req.From on as soon as there are any, or with
+// none once req.Wait is over. It resets the watcher with
+// the whole inventory when the changes are unknown.
+func (h *V1) Watch(req core.WatchRequest, res *core.WatchResponse) (err error) {
+	return h.watch(req, res, nil)
+}
+
+// watch is Watch giving up when stop is closed.
+func (h *V1) watch(req core.WatchRequest, res *core.WatchResponse, stop <-chan struct{}) (err error) {
+	var ok bool
+	if _, res.Status, ok = h.authorize(req.Credentials, "Watch"); !ok {
+		return
+	}
+
+	wait := req.Wait
+	if wait <= 0 {
+		wait = DefaultWatchWait
+	}
+	if wait > MaxWatchWait {
+		wait = MaxWatchWait
+	}
+	if h.maxWait > 0 && wait > h.maxWait {
+		wait = h.maxWait
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		events, epoch, next, known, wake := h.feed.from(req.Epoch, req.From)
+		if !known {
+			return h.reset(res)
+		}
+
+		res.Epoch = epoch
+
+		if len(events) > 0 {
+			res.Events = events
+			res.Next = next
+			res.Status = succeeded("Command executed successfully!")
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			res.Next = next
+			res.Status = succeeded("Command executed successfully!")
+			return
+		case <-stop:
+			res.Next = next
+			res.Status = succeeded("Command executed successfully!")
+			return
+		case <-h.done:
+			res.Next = next
+			res.Status = succeeded("Command executed successfully!")
+			return
+		}
+	}
+}
+
+// reset answers a watcher with the whole inventory. The
+// mutex makes sure no change happens between the listing
+// and the sequence number it is at.
+func (h *V1) reset(res *core.WatchResponse) (err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	res.Vegitables, err = h.Store.List()
+	if err != nil {
+		return
+	}
+
+	_, res.Epoch, res.Next, _, _ = h.feed.from(0, 0)
+	res.Reset = true
+	res.Status = succeeded("Command executed successfully!")
+	return
+}
+
+// serveWatch streams the changes of the inventory over
+// HTTP as JSON lines, each one a core.WatchResponse:
+//
+//	GET /watch?epoch=<epoch>&from=<seq>
+//	Authorization: Bearer <session>
+//
+// The first line resets the watcher unless epoch and from
+// are known.
+// A line without events is sent every wait (the `wait`
+// parameter, a duration) to keep the connection alive.
+func (s *Server) serveWatch(w http.ResponseWriter, r *http.Request) {
+	if !s.enter() {
+		http.Error(w, errClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.serving.Done()
+
+	var req core.WatchRequest
+
+	query := r.URL.Query()
+	if epoch := query.Get("epoch"); epoch != "" {
+		e, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid epoch '"+epoch+"'", http.StatusBadRequest)
+			return
+		}
+
+		req.Epoch = e
+	}
+
+	if from := query.Get("from"); from != "" {
+		seq, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid from '"+from+"'", http.StatusBadRequest)
+			return
+		}
+
+		req.From = seq
+	}
+
+	if wait := query.Get("wait"); wait != "" {
+		d, err := time.ParseDuration(wait)
+		if err != nil {
+			http.Error(w, "invalid wait '"+wait+"'", http.StatusBadRequest)
+			return
+		}
+
+		req.Wait = d
+	}
+
+	// the session is only taken from the header, a URL
+	// ends up in the logs of proxies.
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		req.Session = strings.TrimPrefix(authorization, "Bearer ")
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	for {
+		var res core.WatchResponse
+
+		err := s.v1.watch(req, &res, r.Context().Done())
+		if err != nil || r.Context().Err() != nil {
+			return
+		}
+
+		if encoder.Encode(res) != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if !res.Ok {
+			return
+		}
+
+		req.Epoch = res.Epoch
+		req.From = res.Next
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+func TestFeedBacklog(t *testing.T) {
+	var f feed
+
+	for i := 0; i < watchBacklog+10; i++ {
+		f.publish("update", nil, &core.Vegitable{Name: "Beans"})
+	}
+
+	_, epoch, next, _, _ := f.from(0, 0)
+	if next != watchBacklog+11 {
+		t.Fatalf("next is %d, want %d", next, watchBacklog+11)
+	}
+
+	tests := []struct {
+		seq    uint64
+		ok     bool
+		events int
+	}{
+		{1, false, 0},  // dropped from the backlog
+		{10, false, 0}, // the last one dropped
+		{11, true, watchBacklog},
+		{next - 1, true, 1},
+		{next, true, 0},
+		{next + 1, false, 0}, // never published
+	}
+
+	for _, test := range tests {
+		events, _, _, ok, wake := f.from(epoch, test.seq)
+		if ok != test.ok || len(events) != test.events {
+			t.Errorf("from %d: got %d events, %v, want %d, %v", test.seq, len(events), ok, test.events, test.ok)
+		}
+		if ok && test.events > 0 && events[0].Seq != test.seq {
+			t.Errorf("from %d: the first event is %d", test.seq, events[0].Seq)
+		}
+		if ok && (wake == nil) != (test.events > 0) {
+			t.Errorf("from %d: wake is %v with %d events", test.seq, wake, len(events))
+		}
+	}
+}
+
+// A watcher of another epoch, e.g. of the server before a
+// restart, starts over even if its sequence is known.
+func TestFeedEpochReset(t *testing.T) {
+	var f feed
+	f.publish("add", nil, &core.Vegitable{Name: "Beans"})
+
+	_, epoch, _, _, _ := f.from(0, 0)
+
+	if _, _, _, ok, _ := f.from(epoch-1, 1); ok {
+		t.Error("the events of another epoch are known")
+	}
+	if _, _, _, ok, _ := f.from(epoch, 0); ok {
+		t.Error("a watcher from 0 is not reset")
+	}
+	if events, _, _, ok, _ := f.from(epoch, 1); !ok || len(events) != 1 {
+		t.Errorf("got %d events, %v, want the one published", len(events), ok)
+	}
+}
+
+// The stream only takes the session from the header.
+func TestWatchStreamSession(t *testing.T) {
+	a := loadAuth(t)
+	port := start(t, &Server{Auth: a})
+
+	var login core.SessionResponse
+	if err := (&V1{auth: a}).Login(core.LoginRequest{Token: "t-ann"}, &login); err != nil || !login.Ok {
+		t.Fatalf("login failed: %v %+v", err, login.Status)
+	}
+
+	url := "http://127.0.0.1:" + strconv.Itoa(int(port)) + "/watch"
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   core.ErrorCode
+	}{
+		{"header", url, "Bearer " + login.Session, core.CodeOK},
+		{"query", url + "?session=" + login.Session, "", core.CodeUnauthorized},
+		{"no bearer", url, login.Session, core.CodeUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var res core.WatchResponse
+			if err := json.NewDecoder(bufio.NewReader(resp.Body)).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Code != test.want {
+				t.Fatalf("got %v (%s), want %v", res.Code, res.Message, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+const (
+	// OpPut upserts Mutation.Vegitable.
+	OpPut = "put"
+
+	// OpDelete removes the vegitable called
+	// Mutation.Name.
+	OpDelete = "delete"
+)
+
+// Mutation describes a single change to the inventory as
+// it is recorded in the journal. Time is when the change
+// was made, Apply sets it to the current time when zero.
+type Mutation struct {
+	Op        string         `json:"op"`
+	Name      string         `json:"name,omitempty"`
+	Vegitable core.Vegitable `json:"vegitable"`
+	Time      time.Time      `json:"time"`
+}
+
+// journal is an append-only log of mutations. Each line
+// is a JSON array holding the mutations of one Apply so
+// that a batch is replayed entirely or not at all. Every
+// append is synced to disk before it returns so that an
+// acknowledged mutation survives a crash.
+//
+// A failed append is cut off the journal again, so that
+// neither a partial line nor a batch the caller was told
+// failed is ever replayed. If even that fails the journal
+// is broken: every later append returns err until a
+// checkpoint resets it.
+type journal struct {
+	file journalFile
+	err  error
+}
+
+// journalFile is the part of *os.File the journal uses.
+type journalFile interface {
+	io.Writer
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// openJournal opens (or creates) the journal at path for
+// appending.
+func openJournal(path string) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{file: file}, nil
+}
+
+// readJournal returns the mutations recorded at path. A
+// missing journal has no mutations.
+//
+// A last line that cannot be decoded is the result of a
+// crash in the middle of an append - that batch was never
+// acknowledged so it is dropped. Any other line that
+// cannot be decoded is an error, the batches after it
+// were acknowledged and cannot be given up.
+func readJournal(path string) (mutations []Mutation, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for number := 1; ; number++ {
+		var line []byte
+
+		line, err = reader.ReadBytes('\n')
+		if err == io.EOF {
+			err = nil
+			if len(line) == 0 {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+
+		var batch []Mutation
+		if uerr := json.Unmarshal(line, &batch); uerr != nil {
+			if _, perr := reader.Peek(1); perr == io.EOF {
+				return
+			}
+
+			err = fmt.Errorf("%s:%d: corrupt journal line: %w", path, number, uerr)
+			return
+		}
+
+		mutations = append(mutations, batch...)
+	}
+}
+
+// append writes the mutations as a single line and syncs
+// the file.
+func (j *journal) append(mutations ...Mutation) error {
+	if j.err != nil {
+		return j.err
+	}
+
+	line, err := json.Marshal(mutations)
+	if err != nil {
+		return err
+	}
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	_, err = j.file.Write(append(line, '\n'))
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		j.undo(info.Size())
+	}
+
+	return err
+}
+
+// undo cuts the journal back to size after a failed
+// append, breaking it if that fails too.
+func (j *journal) undo(size int64) {
+	err := j.file.Truncate(size)
+	if err == nil {
+		err = j.file.Sync()
+	}
+
+	if err != nil {
+		j.err = fmt.Errorf("store: journal broken by a failed append: %w", err)
+	}
+}
+
+// reset discards every mutation in the journal once they
+// are safely part of the checkpoint.
+func (j *journal) reset() error {
+	err := j.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	err = j.file.Sync()
+	if err != nil {
+		return err
+	}
+
+	j.err = nil
+	return nil
+}
+
+// empty tells whether nothing is left in the journal.
+func (j *journal) empty() (bool, error) {
+	info, err := j.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	return info.Size() == 0, nil
+}
+
+func (j *journal) close() error {
+	return j.file.Close()
+}
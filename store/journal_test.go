@@ -0,0 +1,272 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+func put(name string, price core.Money, kgs core.Weight) Mutation {
+	return Mutation{Op: OpPut, Vegitable: core.Vegitable{Name: name, PricePerKg: price, RemainingKgs: kgs}}
+}
+
+func writeJournal(t *testing.T, path string, batches ...[]Mutation) {
+	t.Helper()
+
+	j, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.close()
+
+	for _, batch := range batches {
+		if err := j.append(batch...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func appendRaw(t *testing.T, path, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func names(mutations []Mutation) (names []string) {
+	for _, m := range mutations {
+		names = append(names, m.Vegitable.Name+m.Name)
+	}
+
+	return
+}
+
+func TestReadJournalMissing(t *testing.T) {
+	mutations, err := readJournal(filepath.Join(t.TempDir(), "db.xml.journal"))
+	if err != nil || len(mutations) != 0 {
+		t.Fatalf("got %v, %v, want no mutations", mutations, err)
+	}
+}
+
+func TestReadJournalBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml.journal")
+	writeJournal(t, path,
+		[]Mutation{put("Beans", 17500, 10100)},
+		[]Mutation{put("Carrot", 9000, 5000), {Op: OpDelete, Name: "Beans"}},
+	)
+
+	mutations, err := readJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(mutations), ","); got != "Beans,Carrot,Beans" {
+		t.Fatalf("replayed %s, want Beans,Carrot,Beans", got)
+	}
+	if mutations[2].Op != OpDelete {
+		t.Fatalf("last mutation is %q, want %q", mutations[2].Op, OpDelete)
+	}
+}
+
+func TestReadJournalTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml.journal")
+	writeJournal(t, path, []Mutation{put("Beans", 17500, 10100)})
+	appendRaw(t, path, `[{"op":"put","vegitable":{"Name":"Car`)
+
+	mutations, err := readJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(names(mutations), ","); got != "Beans" {
+		t.Fatalf("replayed %s, want Beans", got)
+	}
+}
+
+func TestReadJournalCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml.journal")
+	writeJournal(t, path, []Mutation{put("Beans", 17500, 10100)})
+	appendRaw(t, path, "garbage\n")
+	writeJournal(t, path, []Mutation{put("Carrot", 9000, 5000)})
+
+	_, err := readJournal(path)
+	if err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Fatalf("got %v, want an error for line 2", err)
+	}
+}
+
+func TestReadJournalLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml.journal")
+
+	name := strings.Repeat("x", 2<<20)
+	writeJournal(t, path, []Mutation{put(name, 100, 1000)}, []Mutation{put("Beans", 17500, 10100)})
+
+	mutations, err := readJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mutations) != 2 || mutations[0].Vegitable.Name != name {
+		t.Fatalf("replayed %d mutations, want the long one and Beans", len(mutations))
+	}
+}
+
+func TestOpenXMLReplaysJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml")
+
+	x, err := OpenXML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Put(core.Vegitable{Name: "Beans", PricePerKg: 17500, RemainingKgs: 10100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Apply(put("Carrot", 9000, 5000), Mutation{Op: OpDelete, Name: "Beans"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash: the journal is left behind without a
+	// checkpoint, the last append torn
+	x.journal.close()
+	appendRaw(t, x.journalPath(), `[{"op":"del`)
+
+	x, err = OpenXML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	vegitables, err := x.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vegitables) != 1 || vegitables[0].Name != "Carrot" || vegitables[0].PricePerKg != 9000 {
+		t.Fatalf("got %+v, want only Carrot", vegitables)
+	}
+
+	// the torn line is gone, later appends replay
+	if err := x.Put(core.Vegitable{Name: "Leeks", PricePerKg: 30000, RemainingKgs: 2000}); err != nil {
+		t.Fatal(err)
+	}
+	mutations, err := readJournal(x.journalPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names(mutations), ","); got != "Leeks" {
+		t.Fatalf("journal holds %s, want Leeks", got)
+	}
+}
+
+// faultyFile fails the next write of a journal (after
+// writing half of it) or its next sync once failing is
+// set, and its truncates while truncate is set.
+type faultyFile struct {
+	*os.File
+	failing, sync, truncate bool
+}
+
+var errFault = errors.New("fault")
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.failing && !f.sync {
+		f.failing = false
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errFault
+	}
+
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failing && f.sync {
+		f.failing = false
+		return errFault
+	}
+
+	return f.File.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.truncate {
+		return errFault
+	}
+
+	return f.File.Truncate(size)
+}
+
+func faultyJournal(t *testing.T, path string) (*journal, *faultyFile) {
+	t.Helper()
+
+	j, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.close() })
+
+	f := &faultyFile{File: j.file.(*os.File)}
+	j.file = f
+
+	return j, f
+}
+
+func TestFailedAppendIsUndone(t *testing.T) {
+	for _, sync := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "db.xml.journal")
+		j, f := faultyJournal(t, path)
+
+		if err := j.append(put("Beans", 17500, 10100)); err != nil {
+			t.Fatal(err)
+		}
+
+		f.failing, f.sync = true, sync
+		if err := j.append(put("Carrot", 9000, 5000)); err != errFault {
+			t.Fatalf("got %v, want the fault", err)
+		}
+
+		if err := j.append(put("Leeks", 30000, 2000)); err != nil {
+			t.Fatal(err)
+		}
+
+		mutations, err := readJournal(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(names(mutations), ","); got != "Beans,Leeks" {
+			t.Fatalf("with failing syncs %v, replayed %s, want Beans,Leeks", sync, got)
+		}
+	}
+}
+
+func TestFailedUndoBreaksJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml.journal")
+	j, f := faultyJournal(t, path)
+
+	f.failing, f.truncate = true, true
+	if err := j.append(put("Beans", 17500, 10100)); err != errFault {
+		t.Fatalf("got %v, want the fault", err)
+	}
+
+	f.truncate = false
+	if err := j.append(put("Carrot", 9000, 5000)); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("got %v, want a broken journal", err)
+	}
+
+	// a checkpoint starts it over
+	if err := j.reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.append(put("Leeks", 30000, 2000)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// Memory is a Store that keeps the inventory in memory
+// only. Everything is lost once the process exits.
+type Memory struct {
+	mutex      sync.RWMutex
+	vegitables []core.Vegitable
+	history    map[string][]core.Revision
+	closed     bool
+}
+
+// NewMemory creates an in-memory store seeded with the
+// given vegitables.
+func NewMemory(vegitables ...core.Vegitable) *Memory {
+	m := &Memory{history: make(map[string][]core.Revision)}
+	for _, v := range vegitables {
+		m.seed(v)
+	}
+
+	return m
+}
+
+func (m *Memory) Get(name string) (v core.Vegitable, err error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	i := index(m.vegitables, name)
+	if i < 0 {
+		err = ErrNotFound
+		return
+	}
+
+	v = m.vegitables[i]
+	return
+}
+
+func (m *Memory) List() ([]core.Vegitable, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return append([]core.Vegitable(nil), m.vegitables...), nil
+}
+
+func (m *Memory) History(name string) ([]core.Revision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	revisions, ok := m.history[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]core.Revision(nil), revisions...), nil
+}
+
+func (m *Memory) Put(v core.Vegitable) error {
+	return m.Apply(Mutation{Op: OpPut, Vegitable: v})
+}
+
+func (m *Memory) Delete(name string) error {
+	return m.Apply(Mutation{Op: OpDelete, Name: name})
+}
+
+func (m *Memory) Apply(mutations ...Mutation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	err := validate(m.vegitables, mutations)
+	if err != nil {
+		return err
+	}
+
+	stamp(m.vegitables, mutations)
+	for _, mutation := range mutations {
+		m.apply(mutation)
+	}
+
+	return nil
+}
+
+func (m *Memory) Snapshot() (core.Vegitables, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.snapshot(), nil
+}
+
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closed = true
+	return nil
+}
+
+// snapshot copies the inventory along with the history
+// of every vegitable, sorted by name. The caller must hold
+// the lock.
+func (m *Memory) snapshot() core.Vegitables {
+	snapshot := core.Vegitables{
+		Vegitables: append([]core.Vegitable(nil), m.vegitables...),
+	}
+
+	for name, revisions := range m.history {
+		snapshot.History = append(snapshot.History, core.History{
+			Name:      name,
+			Revisions: append([]core.Revision(nil), revisions...),
+		})
+	}
+	sort.Slice(snapshot.History, func(i, j int) bool {
+		return snapshot.History[i].Name < snapshot.History[j].Name
+	})
+
+	return snapshot
+}
+
+// apply performs the mutation. Deleting a missing
+// vegitable is not an error so that replaying a journal
+// twice is harmless. The caller must hold the write lock.
+func (m *Memory) apply(mutation Mutation) {
+	switch mutation.Op {
+	case OpPut:
+		m.put(mutation.Vegitable, mutation.Time)
+	case OpDelete:
+		m.delete(mutation.Name, mutation.Time)
+	}
+}
+
+// seed adds a vegitable that was stored before versions
+// were kept (or history, see put) as its first version.
+// The caller must hold the write lock.
+func (m *Memory) seed(v core.Vegitable) {
+	if v.Version == 0 {
+		v.Version = 1
+	}
+
+	m.put(v, time.Time{})
+}
+
+// put upserts the vegitable, adding a revision at the
+// given time when its price or stocks change. The caller
+// must hold the write lock.
+func (m *Memory) put(v core.Vegitable, at time.Time) {
+	m.revise(v.Name, core.Revision{Time: at, PricePerKg: v.PricePerKg, RemainingKgs: v.RemainingKgs})
+
+	if i := index(m.vegitables, v.Name); i >= 0 {
+		m.vegitables[i] = v
+		return
+	}
+
+	m.vegitables = append(m.vegitables, v)
+}
+
+// delete removes the vegitable, its history ends with a
+// deleted revision at the given time. The caller must hold
+// the write lock.
+func (m *Memory) delete(name string, at time.Time) error {
+	i := index(m.vegitables, name)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	v := m.vegitables[i]
+	m.revise(name, core.Revision{Time: at, Deleted: true, PricePerKg: v.PricePerKg, RemainingKgs: v.RemainingKgs})
+
+	m.vegitables = append(m.vegitables[:i], m.vegitables[i+1:]...)
+	return nil
+}
+
+// revise appends the revision to the history of the
+// vegitable unless it would not change anything. The
+// caller must hold the write lock.
+func (m *Memory) revise(name string, revision core.Revision) {
+	revisions := m.history[name]
+
+	if n := len(revisions); n > 0 {
+		last := revisions[n-1]
+		if last.Deleted == revision.Deleted && last.PricePerKg == revision.PricePerKg &&
+			last.RemainingKgs == revision.RemainingKgs {
+			return
+		}
+	}
+
+	m.history[name] = append(revisions, revision)
+}
@@ -0,0 +1,153 @@
+// store implements the persistence layer used by the
+// server to keep track of the vegitable inventory.
+//
+// The server only talks to the `Store` interface so the
+// backend (an XML file on disk, plain memory, ...) can be
+// swapped without touching the RPC handlers.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// ErrNotFound is returned when the requested vegitable
+// does not exist in the store.
+var ErrNotFound = errors.New("store: vegitable not found")
+
+// ErrClosed is returned when mutating a store once it is
+// closed.
+var ErrClosed = errors.New("store: closed")
+
+// Store is the interface every inventory backend has to
+// satisfy.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get retrieves the vegitable with the given name.
+	Get(name string) (core.Vegitable, error)
+
+	// List retrieves all the vegitables in the order
+	// they were added.
+	List() ([]core.Vegitable, error)
+
+	// Put adds a new vegitable or replaces an existing
+	// one with the same name.
+	Put(v core.Vegitable) error
+
+	// Delete removes the vegitable with the given name.
+	Delete(name string) error
+
+	// History retrieves the price and stock revisions
+	// of the vegitable with the given name, oldest
+	// first. The history outlives the vegitable.
+	History(name string) ([]core.Revision, error)
+
+	// Apply performs all the mutations as a single
+	// atomic (and, if the backend persists, durable)
+	// change: either every mutation is applied or none.
+	//
+	// Every vegitable put gets the version following
+	// the one it replaces (1 for a new one) or, if
+	// higher, its own. Apply
+	// fills in the Version and the Time of the given
+	// mutations so the caller can see them.
+	Apply(mutations ...Mutation) error
+
+	// Snapshot returns a copy of the whole inventory
+	// along with its history.
+	Snapshot() (core.Vegitables, error)
+
+	// Close releases the resources held by the store.
+	// Apply (and so Put and Delete) fails with ErrClosed
+	// afterwards, reads go on working.
+	Close() error
+}
+
+// Open creates the store for the given backend kind.
+//
+// The path is only used by backends that persist to
+// disk.
+func Open(kind, path string) (Store, error) {
+	switch kind {
+	case "xml", "":
+		return OpenXML(path)
+	case "memory":
+		return NewMemory(), nil
+	}
+
+	return nil, errors.New("store: unknown backend '" + kind + "'")
+}
+
+// validate checks that the mutations can be applied to
+// the vegitables in order, i.e. that every deleted
+// vegitable exists at that point.
+func validate(vegitables []core.Vegitable, mutations []Mutation) error {
+	exists := make(map[string]bool, len(vegitables))
+	for _, v := range vegitables {
+		exists[v.Name] = true
+	}
+
+	for _, m := range mutations {
+		switch m.Op {
+		case OpPut:
+			exists[m.Vegitable.Name] = true
+		case OpDelete:
+			if !exists[m.Name] {
+				return ErrNotFound
+			}
+			exists[m.Name] = false
+		default:
+			return errors.New("store: unknown mutation '" + m.Op + "'")
+		}
+	}
+
+	return nil
+}
+
+// stamp sets the time of the mutations that have none and
+// numbers the version of every vegitable put, in order. A
+// put follows the version of the vegitable it replaces or,
+// when moving a vegitable to a new name, its own.
+func stamp(vegitables []core.Vegitable, mutations []Mutation) {
+	versions := make(map[string]uint64, len(vegitables))
+	for _, v := range vegitables {
+		versions[v.Name] = v.Version
+	}
+
+	now := time.Now()
+	for i := range mutations {
+		m := &mutations[i]
+
+		if m.Time.IsZero() {
+			m.Time = now
+		}
+
+		switch m.Op {
+		case OpPut:
+			version := versions[m.Vegitable.Name]
+			if m.Vegitable.Version > version {
+				version = m.Vegitable.Version
+			}
+
+			m.Vegitable.Version = version + 1
+			versions[m.Vegitable.Name] = version + 1
+		case OpDelete:
+			delete(versions, m.Name)
+		}
+	}
+}
+
+// index returns the position of the vegitable with the
+// given name or -1 if it is not present.
+func index(vegitables []core.Vegitable, name string) int {
+	for i := range vegitables {
+		if vegitables[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+func TestApplyAfterClose(t *testing.T) {
+	x, err := OpenXML(filepath.Join(t.TempDir(), "db.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []Store{NewMemory(core.Vegitable{Name: "Beans"}), x} {
+		if err := s.Put(core.Vegitable{Name: "Carrot", PricePerKg: 9000}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Put(core.Vegitable{Name: "Leeks"}); err != ErrClosed {
+			t.Errorf("%T: Put got %v, want ErrClosed", s, err)
+		}
+		if err := s.Delete("Carrot"); err != ErrClosed {
+			t.Errorf("%T: Delete got %v, want ErrClosed", s, err)
+		}
+		if v, err := s.Get("Carrot"); err != nil || v.PricePerKg != 9000 {
+			t.Errorf("%T: Get got %+v, %v, want Carrot", s, v, err)
+		}
+		if err := s.Close(); err != nil {
+			t.Errorf("%T: second Close got %v", s, err)
+		}
+	}
+}
+
+// A document written before the amounts were checked
+// still opens, an invalid amount is loaded as 0.
+func TestOpenXMLInvalidAmounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.xml")
+
+	doc := `<vegitables>
+	<vegitable><name>Beans</name><pricePerKg>175.50</pricePerKg><remainingKgs>abc</remainingKgs></vegitable>
+	<vegitable><name>Carrot</name><pricePerKg></pricePerKg><remainingKgs>10</remainingKgs></vegitable>
+</vegitables>`
+	if err := ioutil.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, err := OpenXML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+
+	vegitables, err := x.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []core.Vegitable{{Name: "Beans", PricePerKg: 17550}, {Name: "Carrot", RemainingKgs: 10000}}
+	if len(vegitables) != len(want) {
+		t.Fatalf("got %+v, want %+v", vegitables, want)
+	}
+	for i, v := range vegitables {
+		if v.Name != want[i].Name || v.PricePerKg != want[i].PricePerKg || v.RemainingKgs != want[i].RemainingKgs {
+			t.Errorf("got %+v, want %+v", v, want[i])
+		}
+	}
+}
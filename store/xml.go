@@ -0,0 +1,259 @@
+package store
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dimalkavindu/go-rpc/core"
+)
+
+// checkpointEvery is the number of journaled mutations
+// after which the XML document is rewritten and the
+// journal truncated.
+const checkpointEvery = 64
+
+// XML is a Store that persists the inventory as an XML
+// document.
+//
+// Every mutation is first appended to a journal living
+// next to the document (`<path>.journal`) and synced to
+// disk, so it is durable as soon as Put or Delete return.
+// The document itself is only rewritten periodically (and
+// on Open/Close) through a temporary file that is synced
+// and then renamed over the original, meaning a crash
+// never leaves a half-written document behind.
+type XML struct {
+	*Memory
+	path    string
+	journal *journal
+	pending int
+}
+
+// document is the XML document as it is read. The
+// amounts of the vegitables are read as text, as they
+// were before they became decimals, so that a document
+// holding an invalid one still opens (see OpenXML).
+type document struct {
+	XMLName    xml.Name `xml:"vegitables"`
+	Vegitables []struct {
+		Name         string `xml:"name"`
+		PricePerKg   string `xml:"pricePerKg"`
+		RemainingKgs string `xml:"remainingKgs"`
+		Version      uint64 `xml:"version,omitempty"`
+	} `xml:"vegitable"`
+	History []core.History `xml:"history>vegitable,omitempty"`
+}
+
+// OpenXML loads the XML document at path into memory and
+// replays any mutation left in its journal. A missing
+// file is treated as an empty inventory.
+//
+// A unit price or stocks that is not a valid decimal,
+// which the document could hold before they were checked,
+// is loaded as 0 with a warning naming the vegitable.
+func OpenXML(path string) (x *XML, err error) {
+	x = &XML{Memory: NewMemory(), path: path}
+
+	byteValue, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+
+	if len(byteValue) > 0 {
+		var doc document
+		err = xml.Unmarshal(byteValue, &doc)
+		if err != nil {
+			return
+		}
+
+		for _, h := range doc.History {
+			x.history[h.Name] = h.Revisions
+		}
+
+		// a document written before history was kept
+		// starts the history of every vegitable.
+		for _, d := range doc.Vegitables {
+			var perr error
+			v := core.Vegitable{Name: d.Name, Version: d.Version}
+
+			if v.PricePerKg, perr = core.ParseMoney(d.PricePerKg); perr != nil {
+				log.Printf("%s: vegitable '%s': pricePerKg: %v, loaded as 0\n", path, d.Name, perr)
+			}
+			if v.RemainingKgs, perr = core.ParseWeight(d.RemainingKgs); perr != nil {
+				log.Printf("%s: vegitable '%s': remainingKgs: %v, loaded as 0\n", path, d.Name, perr)
+			}
+
+			x.seed(v)
+		}
+	}
+
+	mutations, err := readJournal(x.journalPath())
+	if err != nil {
+		return
+	}
+
+	for _, m := range mutations {
+		x.apply(m)
+	}
+
+	x.journal, err = openJournal(x.journalPath())
+	if err != nil {
+		return
+	}
+
+	// a torn last line is cleared too, or the next append
+	// would be written on the end of it.
+	empty, err := x.journal.empty()
+	if err != nil {
+		return
+	}
+
+	if !empty {
+		err = x.checkpoint()
+	}
+
+	return
+}
+
+func (x *XML) Put(v core.Vegitable) error {
+	return x.Apply(Mutation{Op: OpPut, Vegitable: v})
+}
+
+func (x *XML) Delete(name string) error {
+	return x.Apply(Mutation{Op: OpDelete, Name: name})
+}
+
+// Apply journals all the mutations with a single synced
+// write before applying them in memory.
+//
+// The mutations are committed once journaled, a failed
+// checkpoint afterwards is only logged: the journal still
+// holds them and the next checkpoint tries again.
+func (x *XML) Apply(mutations ...Mutation) (err error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	if x.closed {
+		err = ErrClosed
+		return
+	}
+
+	err = validate(x.vegitables, mutations)
+	if err != nil {
+		return
+	}
+
+	// the time and versions are journaled so that a
+	// replay recreates the same history.
+	stamp(x.vegitables, mutations)
+
+	err = x.journal.append(mutations...)
+	if err != nil {
+		return
+	}
+
+	for _, m := range mutations {
+		x.apply(m)
+	}
+
+	x.pending += len(mutations)
+	if x.pending >= checkpointEvery {
+		if cerr := x.checkpoint(); cerr != nil {
+			log.Println("cannot checkpoint", x.path+":", cerr)
+		}
+	}
+
+	return
+}
+
+// Close writes a final checkpoint and closes the journal.
+func (x *XML) Close() (err error) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.closed = true
+	if x.journal == nil {
+		return
+	}
+
+	if x.pending > 0 {
+		err = x.checkpoint()
+	}
+
+	if cerr := x.journal.close(); err == nil {
+		err = cerr
+	}
+	x.journal = nil
+
+	return
+}
+
+func (x *XML) journalPath() string {
+	return x.path + ".journal"
+}
+
+// checkpoint atomically replaces the document with the
+// current inventory and empties the journal. The caller
+// must hold the write lock.
+func (x *XML) checkpoint() (err error) {
+	dir := filepath.Dir(x.path)
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(x.path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	err = tmp.Chmod(0644)
+	if err != nil {
+		return
+	}
+
+	encoder := xml.NewEncoder(tmp)
+	err = encoder.Encode(x.snapshot())
+	if err != nil {
+		return
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		return
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(tmp.Name(), x.path)
+	if err != nil {
+		return
+	}
+
+	err = syncDir(dir)
+	if err != nil {
+		return
+	}
+
+	x.pending = 0
+	return x.journal.reset()
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}